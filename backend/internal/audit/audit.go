@@ -0,0 +1,252 @@
+// Package audit implements an append-only audit trail stored as JSON lines,
+// recording who did what through FSM and with which outcome. The file is rotated
+// once it grows past a size limit, keeping a bounded number of older files.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded in Entry.Outcome.
+const (
+	OutcomeDenied = "denied"
+	OutcomeError  = "error"
+	OutcomeOK     = "ok"
+)
+
+// Entry is a single audited action.
+type Entry struct {
+	Action  string            `json:"action"`           // What was done, e.g. "rcon.command"
+	Detail  string            `json:"detail,omitempty"` // Error message or other context
+	IP      string            `json:"ip,omitempty"`     // Source address of the request
+	Outcome string            `json:"outcome"`          // One of ok, error or denied
+	Params  map[string]string `json:"params,omitempty"` // Parameters of the action
+	Route   string            `json:"route,omitempty"`  // HTTP method and route template, for actions done through the API
+	Status  int               `json:"status,omitempty"` // HTTP status of the response
+	Time    time.Time         `json:"time"`             // When the action happened
+	User    string            `json:"user"`             // FSM admin who performed the action
+}
+
+// Rotation limits the size of the audit log. Zero values disable rotation.
+type Rotation struct {
+	MaxFiles int   // Rotated files kept besides the current one
+	MaxSize  int64 // Size in bytes at which the current file is rotated
+}
+
+// Filter selects entries returned by Query. Zero values match everything.
+type Filter struct {
+	Action   string    // Exact action, or a prefix when ending in "*"
+	Contains string    // Substring that must appear in one of the parameters
+	From     time.Time // Earliest entry time
+	Limit    int       // Maximum number of entries returned
+	To       time.Time // Latest entry time
+	User     string    // FSM admin username
+}
+
+// Log is an append-only audit log file.
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	rotation Rotation
+}
+
+// New returns an audit log writing to path, rotated according to rotation.
+// Rotated files are named path.1 (most recent) to path.N.
+func New(path string, rotation Rotation) *Log {
+	return &Log{path: path, rotation: rotation}
+}
+
+// SetRotation replaces the rotation limits, e.g. after the config is reloaded.
+func (l *Log) SetRotation(rotation Rotation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rotation = rotation
+}
+
+// Record appends an entry to the log, stamping it with the current time if unset.
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	if err := l.rotate(int64(len(data)) + 1); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Query returns the entries matching filter, newest first.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := []Entry{}
+	for _, path := range l.files() {
+		matched, err := readEntries(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, matched...)
+	}
+	reverse(entries)
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// rotate moves the current file aside when writing size more bytes would exceed
+// the size limit, shifting older files up and removing the oldest. Callers must hold l.mu.
+func (l *Log) rotate(size int64) error {
+	if l.rotation.MaxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= l.rotation.MaxSize {
+		return nil
+	}
+
+	if l.rotation.MaxFiles <= 0 {
+		return os.Remove(l.path)
+	}
+	for _, path := range l.rotated() {
+		n, _ := strconv.Atoi(path[strings.LastIndex(path, ".")+1:])
+		if n >= l.rotation.MaxFiles {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(path, l.rotatedPath(n+1)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(l.path, l.rotatedPath(1))
+}
+
+// files returns the rotated files, oldest first, followed by the current file.
+func (l *Log) files() []string {
+	return append(l.rotated(), l.path)
+}
+
+// rotated returns the existing rotated files, oldest (highest number) first.
+func (l *Log) rotated() []string {
+	matches, _ := filepath.Glob(l.path + ".*")
+	numbered := map[int]string{}
+	var numbers []int
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, l.path+"."))
+		if err != nil || n < 1 {
+			continue
+		}
+		numbered[n] = match
+		numbers = append(numbers, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+
+	paths := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		paths = append(paths, numbered[n])
+	}
+	return paths
+}
+
+func (l *Log) rotatedPath(n int) string {
+	return l.path + "." + strconv.Itoa(n)
+}
+
+// readEntries returns the matching entries of a single file in the order written.
+func readEntries(path string, filter Filter) ([]Entry, error) {
+	entries := []Entry{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// matches reports whether entry satisfies the filter.
+func (f Filter) matches(entry Entry) bool {
+	if f.User != "" && entry.User != f.User {
+		return false
+	}
+	if f.Action != "" {
+		if prefix, ok := strings.CutSuffix(f.Action, "*"); ok {
+			if !strings.HasPrefix(entry.Action, prefix) {
+				return false
+			}
+		} else if entry.Action != f.Action {
+			return false
+		}
+	}
+	if !f.From.IsZero() && entry.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && entry.Time.After(f.To) {
+		return false
+	}
+	if f.Contains != "" {
+		needle := strings.ToLower(f.Contains)
+		for _, v := range entry.Params {
+			if strings.Contains(strings.ToLower(v), needle) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// reverse reverses entries in place.
+func reverse(entries []Entry) {
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+}
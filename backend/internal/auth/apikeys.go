@@ -0,0 +1,252 @@
+package auth
+
+// Named API keys for automation. Only a SHA-256 hash of each key is stored; the
+// key itself is shown once, when it is created.
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyPrefix starts every API key, distinguishing them from session tokens.
+const APIKeyPrefix = "fsm_"
+
+// lastUsedInterval limits how often last-used timestamps are written to disk.
+const lastUsedInterval = time.Minute
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid or expired api key")
+)
+
+// APIKey is a named credential restricted to a set of scopes.
+type APIKey struct {
+	Created   time.Time    `json:"created"`
+	CreatedBy string       `json:"created_by"`
+	Expires   *time.Time   `json:"expires,omitempty"`
+	Hash      string       `json:"hash,omitempty"`
+	ID        string       `json:"id"`
+	LastUsed  *time.Time   `json:"last_used,omitempty"`
+	Name      string       `json:"name"`
+	Scopes    []Permission `json:"scopes"`
+}
+
+// HasScope reports whether the key grants perm.
+func (k APIKey) HasScope(perm Permission) bool {
+	for _, scope := range k.Scopes {
+		if scope == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidScope reports whether perm may be granted to an API key. Keys can never
+// manage FSM admins or other keys.
+func IsValidScope(perm Permission) bool {
+	switch perm {
+	case PermFactorioAdmins, PermMods, PermPlayers, PermRCon, PermSaves, PermServerControl, PermSettings, PermVersions, PermView:
+		return true
+	}
+	return false
+}
+
+// KeyStore persists API keys to a JSON file.
+type KeyStore struct {
+	mu    sync.Mutex
+	keys  map[string]*APIKey
+	path  string
+	saved time.Time
+}
+
+// OpenKeyStore loads the API keys stored at path, creating an empty store if it does not exist.
+func OpenKeyStore(path string) (*KeyStore, error) {
+	store := &KeyStore{keys: map[string]*APIKey{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, key := range keys {
+		store.keys[key.ID] = key
+	}
+	return store, nil
+}
+
+// Create generates a new API key and returns it together with its metadata.
+// The returned key cannot be recovered later.
+func (s *KeyStore) Create(name, createdBy string, scopes []Permission, expires *time.Time) (string, APIKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", APIKey{}, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	key := &APIKey{
+		Created:   time.Now(),
+		CreatedBy: createdBy,
+		Expires:   expires,
+		Hash:      hashSecret(hex.EncodeToString(secret)),
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Scopes:    scopes,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		return "", APIKey{}, err
+	}
+	return APIKeyPrefix + key.ID + "_" + hex.EncodeToString(secret), key.redacted(), nil
+}
+
+// Authenticate returns the key matching token, recording when it was last used.
+func (s *KeyStore) Authenticate(token string) (APIKey, error) {
+	rest, ok := strings.CutPrefix(token, APIKeyPrefix)
+	if !ok {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(secret))) != 1 {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.Expires != nil && !key.Expires.After(now) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	key.LastUsed = &now
+	if now.Sub(s.saved) >= lastUsedInterval {
+		s.save()
+	}
+	return key.redacted(), nil
+}
+
+// List returns all keys, or only those created by user when user is not empty,
+// newest first.
+func (s *KeyStore) List(user string) []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []APIKey{}
+	for _, key := range s.keys {
+		if user == "" || key.CreatedBy == user {
+			keys = append(keys, key.redacted())
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Created.After(keys[j].Created)
+	})
+	return keys
+}
+
+// Get returns the key with the given id.
+func (s *KeyStore) Get(id string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key.redacted(), nil
+}
+
+// Revoke deletes the key with the given id.
+func (s *KeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	return s.save()
+}
+
+// RevokeUser deletes every key created by user.
+func (s *KeyStore) RevokeUser(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revoked := false
+	for id, key := range s.keys {
+		if key.CreatedBy == user {
+			delete(s.keys, id)
+			revoked = true
+		}
+	}
+	if !revoked {
+		return nil
+	}
+	return s.save()
+}
+
+// redacted returns a copy of the key without its hash.
+func (k *APIKey) redacted() APIKey {
+	key := *k
+	key.Hash = ""
+	return key
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// save writes the keys to disk atomically. Callers must hold s.mu.
+func (s *KeyStore) save() error {
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.saved = time.Now()
+	return nil
+}
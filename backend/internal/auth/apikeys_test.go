@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeyStore(t *testing.T) (*KeyStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	store, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("OpenKeyStore() = %v", err)
+	}
+	return store, path
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	store, _ := newTestKeyStore(t)
+	token, key, err := store.Create("ci", "alice", []Permission{PermView}, nil)
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	expired, _, err := store.Create("old", "alice", []Permission{PermView}, &past)
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	id, secret, _ := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), "_")
+	_, otherKey, _ := store.Create("other", "bob", []Permission{PermView}, nil)
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "valid", token: token, valid: true},
+		{name: "empty", token: ""},
+		{name: "missing prefix", token: strings.TrimPrefix(token, APIKeyPrefix)},
+		{name: "missing secret", token: APIKeyPrefix + id},
+		{name: "wrong secret", token: APIKeyPrefix + id + "_" + strings.Repeat("0", len(secret))},
+		{name: "secret of another key", token: APIKeyPrefix + otherKey.ID + "_" + secret},
+		{name: "unknown id", token: APIKeyPrefix + "0000000000000000_" + secret},
+		{name: "hash instead of secret", token: APIKeyPrefix + id + "_" + hashSecret(secret)},
+		{name: "expired", token: expired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Authenticate(tt.token)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidAPIKey) {
+					t.Fatalf("Authenticate() = %v, want ErrInvalidAPIKey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() = %v", err)
+			}
+			if got.ID != key.ID || got.CreatedBy != "alice" {
+				t.Errorf("Authenticate() = %+v, want key %s of alice", got, key.ID)
+			}
+			if got.Hash != "" {
+				t.Error("Authenticate() returned the key hash")
+			}
+			if got.LastUsed == nil {
+				t.Error("LastUsed not recorded")
+			}
+		})
+	}
+}
+
+func TestAPIKeysStoreOnlyHashes(t *testing.T) {
+	store, path := newTestKeyStore(t)
+	token, key, err := store.Create("ci", "alice", []Permission{PermView}, nil)
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if key.Hash != "" {
+		t.Error("Create() returned the key hash")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() = %v", err)
+	}
+	_, secret, _ := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), "_")
+	if strings.Contains(string(data), secret) {
+		t.Error("key store contains the secret")
+	}
+	if !strings.Contains(string(data), hashSecret(secret)) {
+		t.Error("key store does not contain the secret hash")
+	}
+
+	reopened, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("OpenKeyStore() = %v", err)
+	}
+	if _, err := reopened.Authenticate(token); err != nil {
+		t.Errorf("Authenticate() after reopen = %v", err)
+	}
+}
+
+func TestAPIKeyRevoke(t *testing.T) {
+	store, _ := newTestKeyStore(t)
+	first, firstKey, _ := store.Create("first", "alice", []Permission{PermView}, nil)
+	second, _, _ := store.Create("second", "alice", []Permission{PermView}, nil)
+	other, _, _ := store.Create("other", "bob", []Permission{PermView}, nil)
+
+	if err := store.Revoke(firstKey.ID); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	if err := store.Revoke(firstKey.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("second Revoke() = %v, want ErrAPIKeyNotFound", err)
+	}
+	if _, err := store.Authenticate(first); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("revoked key: Authenticate() = %v, want ErrInvalidAPIKey", err)
+	}
+
+	if err := store.RevokeUser("alice"); err != nil {
+		t.Fatalf("RevokeUser() = %v", err)
+	}
+	if _, err := store.Authenticate(second); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("after RevokeUser: Authenticate() = %v, want ErrInvalidAPIKey", err)
+	}
+	if _, err := store.Authenticate(other); err != nil {
+		t.Errorf("key of other user: Authenticate() = %v", err)
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	key := APIKey{Scopes: []Permission{PermView, PermSaves}}
+	for _, tt := range []struct {
+		perm Permission
+		want bool
+	}{
+		{PermView, true},
+		{PermSaves, true},
+		{PermRCon, false},
+		{PermAdmins, false},
+	} {
+		if got := key.HasScope(tt.perm); got != tt.want {
+			t.Errorf("HasScope(%s) = %v, want %v", tt.perm, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		perm Permission
+		want bool
+	}{
+		{PermView, true},
+		{PermFactorioAdmins, true},
+		{PermAdmins, false},
+		{"unknown", false},
+	} {
+		if got := IsValidScope(tt.perm); got != tt.want {
+			t.Errorf("IsValidScope(%s) = %v, want %v", tt.perm, got, tt.want)
+		}
+	}
+}
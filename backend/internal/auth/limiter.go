@@ -0,0 +1,254 @@
+package auth
+
+// Login throttling: failed logins are counted per client IP and per username,
+// enforcing progressively longer waits between attempts and temporary lockouts
+// once too many attempts have failed.
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of keys a LoginLimiter tracks.
+const (
+	LimitIP   = "ip"
+	LimitUser = "user"
+)
+
+const (
+	maxLoginDelay  = time.Minute      // Caps the wait enforced between failed attempts before lockout
+	pendingTimeout = 30 * time.Second // Attempts neither failed nor released by then are forgotten
+	pendingWait    = time.Second      // Wait reported while pending attempts may still cause a lockout
+)
+
+// LimiterPolicy configures a LoginLimiter.
+type LimiterPolicy struct {
+	DelayAfter      int           // Failures allowed before waits are enforced
+	LockoutAfter    int           // Failures that lock the key out
+	LockoutDuration time.Duration // How long a lockout lasts; failures older than this are forgotten
+}
+
+// Lockout describes the failed attempts recorded for an IP or username.
+type Lockout struct {
+	Failures    int        `json:"failures"`
+	Kind        string     `json:"kind"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Value       string     `json:"value"`
+}
+
+type limiterEntry struct {
+	failures    int
+	lastAttempt time.Time
+	lastFailure time.Time
+	lockedUntil time.Time
+	pending     int // Attempts allowed but not yet failed or released
+}
+
+// LoginLimiter tracks failed login attempts in memory.
+type LoginLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*limiterEntry
+	policy  LimiterPolicy
+}
+
+// NewLoginLimiter returns a limiter enforcing policy.
+func NewLoginLimiter(policy LimiterPolicy) *LoginLimiter {
+	return &LoginLimiter{entries: map[string]*limiterEntry{}, policy: policy}
+}
+
+// SetPolicy replaces the policy, e.g. after the config is reloaded.
+func (l *LoginLimiter) SetPolicy(policy LimiterPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = policy
+}
+
+// Allow reports how long the client must wait before another attempt for ip and
+// username is considered. A zero duration means the attempt may proceed; it is
+// then counted as pending until it is passed to Fail, Succeed or Release. Waits
+// between attempts only depend on recorded failures, but pending attempts count
+// toward the lockout threshold, so concurrent guesses cannot outrun a lockout.
+func (l *LoginLimiter) Allow(ip, username string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	keys := []string{limiterKey(LimitIP, ip), limiterKey(LimitUser, username)}
+	var wait time.Duration
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if !ok {
+			continue
+		}
+		if d := entry.lockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+		if d := entry.lastAttempt.Add(l.delay(entry.failures)).Sub(now); d > wait {
+			wait = d
+		}
+		if l.policy.LockoutAfter > 0 && entry.failures+entry.pending >= l.policy.LockoutAfter {
+			wait = max(wait, pendingWait)
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if !ok {
+			entry = &limiterEntry{}
+			l.entries[key] = entry
+		}
+		entry.pending++
+		entry.lastAttempt = now
+	}
+	return 0
+}
+
+// Fail records a pending attempt for ip and username as failed. It returns true
+// when the failure caused either of them to be locked out.
+func (l *LoginLimiter) Fail(ip, username string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	locked := false
+	for _, key := range []string{limiterKey(LimitIP, ip), limiterKey(LimitUser, username)} {
+		entry, ok := l.entries[key]
+		if !ok {
+			entry = &limiterEntry{lastAttempt: now}
+			l.entries[key] = entry
+		}
+		entry.pending = max(entry.pending-1, 0)
+		entry.failures++
+		entry.lastFailure = now
+		if l.policy.LockoutAfter > 0 && entry.failures >= l.policy.LockoutAfter && entry.lockedUntil.Before(now) {
+			entry.lockedUntil = now.Add(l.policy.LockoutDuration)
+			locked = true
+		}
+	}
+	return locked
+}
+
+// Succeed completes a pending attempt and forgets the failures recorded for
+// username. Failures of the client IP are kept, so one valid account cannot be
+// used to keep guessing others.
+func (l *LoginLimiter) Succeed(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release(limiterKey(LimitIP, ip))
+	delete(l.entries, limiterKey(LimitUser, username))
+}
+
+// Release drops a pending attempt without counting it, e.g. when the password
+// was correct but a second factor is still required.
+func (l *LoginLimiter) Release(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release(limiterKey(LimitIP, ip))
+	l.release(limiterKey(LimitUser, username))
+}
+
+// release drops a pending attempt of key, forgetting the entry if nothing else
+// is recorded for it. Callers must hold l.mu.
+func (l *LoginLimiter) release(key string) {
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	entry.pending = max(entry.pending-1, 0)
+	if entry.pending == 0 && entry.failures == 0 {
+		delete(l.entries, key)
+	}
+}
+
+// Lockouts returns every IP and username with recorded failures, most recent first.
+func (l *LoginLimiter) Lockouts() []Lockout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	lockouts := []Lockout{}
+	for key, entry := range l.entries {
+		if entry.failures == 0 {
+			continue
+		}
+		kind, value, _ := strings.Cut(key, ":")
+		lockout := Lockout{
+			Failures:    entry.failures,
+			Kind:        kind,
+			LastFailure: entry.lastFailure,
+			Value:       value,
+		}
+		if entry.lockedUntil.After(now) {
+			until := entry.lockedUntil
+			lockout.LockedUntil = &until
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LastFailure.After(lockouts[j].LastFailure)
+	})
+	return lockouts
+}
+
+// Clear forgets the failures recorded for a single IP or username and reports
+// whether there were any.
+func (l *LoginLimiter) Clear(kind, value string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := limiterKey(kind, value)
+	_, ok := l.entries[key]
+	delete(l.entries, key)
+	return ok
+}
+
+// ClearAll forgets every recorded failure.
+func (l *LoginLimiter) ClearAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = map[string]*limiterEntry{}
+}
+
+// delay returns the wait enforced after the given number of failures: nothing up
+// to DelayAfter, then one second doubling with each further failure.
+func (l *LoginLimiter) delay(failures int) time.Duration {
+	excess := failures - l.policy.DelayAfter
+	if excess <= 0 {
+		return 0
+	}
+	if excess > 6 {
+		return maxLoginDelay
+	}
+	return min(time.Second<<(excess-1), maxLoginDelay)
+}
+
+// prune forgets pending attempts older than pendingTimeout, then entries whose
+// lockout has expired and whose last failure is older than the lockout duration.
+// Callers must hold l.mu.
+func (l *LoginLimiter) prune(now time.Time) {
+	for key, entry := range l.entries {
+		if entry.pending > 0 && now.Sub(entry.lastAttempt) > pendingTimeout {
+			entry.pending = 0
+		}
+		if entry.pending > 0 || entry.lockedUntil.After(now) {
+			continue
+		}
+		if !entry.lockedUntil.IsZero() || now.Sub(entry.lastFailure) > l.policy.LockoutDuration {
+			delete(l.entries, key)
+		}
+	}
+}
+
+func limiterKey(kind, value string) string {
+	return kind + ":" + value
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiterDelay(t *testing.T) {
+	l := NewLoginLimiter(LimiterPolicy{DelayAfter: 3})
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{9, 32 * time.Second},
+		{10, maxLoginDelay},
+		{100, maxLoginDelay},
+	}
+	for _, tt := range tests {
+		if got := l.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLimiterThrottles(t *testing.T) {
+	l := NewLoginLimiter(LimiterPolicy{DelayAfter: 2, LockoutAfter: 10, LockoutDuration: time.Hour})
+	for i := 0; i < 2; i++ {
+		if wait := l.Allow("192.0.2.1", "alice"); wait != 0 {
+			t.Fatalf("attempt %d: Allow() = %v, want 0", i+1, wait)
+		}
+		l.Fail("192.0.2.1", "alice")
+	}
+	if wait := l.Allow("192.0.2.1", "alice"); wait != 0 {
+		t.Fatalf("attempt 3: Allow() = %v, want 0", wait)
+	}
+	l.Fail("192.0.2.1", "alice")
+
+	tests := []struct {
+		name     string
+		ip       string
+		username string
+		wait     bool
+	}{
+		{name: "same ip and user", ip: "192.0.2.1", username: "alice", wait: true},
+		{name: "same ip", ip: "192.0.2.1", username: "bob", wait: true},
+		{name: "same user", ip: "192.0.2.2", username: "alice", wait: true},
+		{name: "other ip and user", ip: "192.0.2.2", username: "bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait := l.Allow(tt.ip, tt.username)
+			if tt.wait && (wait <= 0 || wait > time.Second) {
+				t.Errorf("Allow() = %v, want a wait of up to 1s", wait)
+			}
+			if !tt.wait && wait != 0 {
+				t.Errorf("Allow() = %v, want 0", wait)
+			}
+			if wait == 0 {
+				l.Release(tt.ip, tt.username)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterLockout(t *testing.T) {
+	l := NewLoginLimiter(LimiterPolicy{DelayAfter: 3, LockoutAfter: 3, LockoutDuration: time.Hour})
+	for i := 1; i <= 3; i++ {
+		if wait := l.Allow("192.0.2.1", "alice"); wait != 0 {
+			t.Fatalf("attempt %d: Allow() = %v, want 0", i, wait)
+		}
+		if locked := l.Fail("192.0.2.1", "alice"); locked != (i == 3) {
+			t.Errorf("attempt %d: Fail() = %v", i, locked)
+		}
+	}
+	if wait := l.Allow("192.0.2.1", "alice"); wait < 59*time.Minute {
+		t.Errorf("Allow() = %v, want the lockout duration", wait)
+	}
+
+	lockouts := l.Lockouts()
+	if len(lockouts) != 2 {
+		t.Fatalf("Lockouts() = %+v, want ip and user", lockouts)
+	}
+	for _, lockout := range lockouts {
+		if lockout.Failures != 3 || lockout.LockedUntil == nil {
+			t.Errorf("lockout %s %s = %+v, want 3 failures and locked", lockout.Kind, lockout.Value, lockout)
+		}
+	}
+
+	if !l.Clear(LimitUser, "alice") {
+		t.Error("Clear() = false, want true")
+	}
+	if l.Clear(LimitUser, "alice") {
+		t.Error("second Clear() = true, want false")
+	}
+	if wait := l.Allow("192.0.2.2", "alice"); wait != 0 {
+		t.Errorf("after Clear: Allow() = %v, want 0", wait)
+	}
+}
+
+func TestLoginLimiterReservesConcurrentAttempts(t *testing.T) {
+	l := NewLoginLimiter(LimiterPolicy{DelayAfter: 3, LockoutAfter: 10, LockoutDuration: time.Hour})
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Allow("192.0.2.1", "alice") == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Pending attempts count toward the lockout, so only LockoutAfter get
+	// through before the first of them has been resolved.
+	if got := allowed.Load(); got != 10 {
+		t.Errorf("allowed %d concurrent attempts, want 10", got)
+	}
+}
+
+func TestLoginLimiterDoesNotDelayPending(t *testing.T) {
+	l := NewLoginLimiter(LimiterPolicy{DelayAfter: 3, LockoutAfter: 10, LockoutDuration: time.Hour})
+
+	// Concurrent requests with valid credentials, e.g. Basic auth, must not be
+	// throttled as long as nothing has failed.
+	for i := 1; i <= 6; i++ {
+		if wait := l.Allow("192.0.2.1", "alice"); wait != 0 {
+			t.Fatalf("attempt %d: Allow() = %v, want 0", i, wait)
+		}
+	}
+	for i := 1; i <= 6; i++ {
+		l.Succeed("192.0.2.1", "alice")
+	}
+	if len(l.entries) != 0 {
+		t.Errorf("after Succeed: %d entries, want 0", len(l.entries))
+	}
+}
+
+func TestLoginLimiterResolvesPending(t *testing.T) {
+	l := NewLoginLimiter(LimiterPolicy{DelayAfter: 1, LockoutAfter: 5, LockoutDuration: time.Hour})
+
+	l.Allow("192.0.2.1", "alice")
+	l.Release("192.0.2.1", "alice")
+	if len(l.entries) != 0 {
+		t.Errorf("after Release: %d entries, want 0", len(l.entries))
+	}
+
+	l.Allow("192.0.2.1", "alice")
+	l.Fail("192.0.2.1", "alice")
+	l.Allow("192.0.2.1", "alice")
+	l.Succeed("192.0.2.1", "alice")
+
+	if _, ok := l.entries[limiterKey(LimitUser, "alice")]; ok {
+		t.Error("Succeed() kept the failures of the user")
+	}
+	ip, ok := l.entries[limiterKey(LimitIP, "192.0.2.1")]
+	if !ok {
+		t.Fatal("Succeed() forgot the failures of the ip")
+	}
+	if ip.failures != 1 || ip.pending != 0 {
+		t.Errorf("ip entry = %+v, want 1 failure and nothing pending", ip)
+	}
+	if lockouts := l.Lockouts(); len(lockouts) != 1 || lockouts[0].Kind != LimitIP {
+		t.Errorf("Lockouts() = %+v, want only the ip", lockouts)
+	}
+}
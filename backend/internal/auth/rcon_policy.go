@@ -0,0 +1,108 @@
+package auth
+
+// The RCON command policy, which restricts the commands an FSM admin may run
+// based on their role.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultCommandRules are applied to roles without a configured policy.
+// Roles missing from this map may not run any command.
+var defaultCommandRules = map[string]struct{ allow, deny []string }{
+	RoleOwner: {
+		allow: []string{"*"},
+	},
+	RoleOperator: {
+		allow: []string{"*"},
+	},
+	RoleModerator: {
+		allow: []string{
+			"/admins", "/ban *", "/bans", "/help*", "/kick *", "/mute *", "/mutes",
+			"/players*", "/shout *", "/unban *", "/unmute *", "/w *", "/whisper *",
+		},
+	},
+	RoleViewer: {
+		deny: []string{"*"},
+	},
+}
+
+// CommandPolicy decides whether an RCON command may be run.
+type CommandPolicy struct {
+	allow []rule
+	deny  []rule
+}
+
+// CommandDeniedError is returned when a command is rejected by a policy.
+type CommandDeniedError struct {
+	Command string
+	Role    string
+	Rule    string
+}
+
+// rule is a compiled command pattern.
+type rule struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (e *CommandDeniedError) Error() string {
+	if e.Rule == "" {
+		return fmt.Sprintf("command %q is not permitted for role %s", e.Command, e.Role)
+	}
+	return fmt.Sprintf("command %q is not permitted for role %s (matches %q)", e.Command, e.Role, e.Rule)
+}
+
+// NewCommandPolicy builds a policy from allow and deny patterns. Patterns are
+// matched case-insensitively against the whole command, with "*" matching any
+// sequence of characters. Deny rules take precedence and a command must match an
+// allow rule, so a policy with only deny rules permits nothing; allow "*" to
+// permit everything but the denied commands.
+func NewCommandPolicy(allow []string, deny []string) *CommandPolicy {
+	return &CommandPolicy{allow: compileRules(allow), deny: compileRules(deny)}
+}
+
+// DefaultCommandPolicy returns the built-in policy for a role, denying every
+// command for roles it does not know.
+func DefaultCommandPolicy(role string) *CommandPolicy {
+	rules, ok := defaultCommandRules[role]
+	if !ok {
+		return NewCommandPolicy(nil, []string{"*"})
+	}
+	return NewCommandPolicy(rules.allow, rules.deny)
+}
+
+// Check returns a CommandDeniedError if the policy does not permit command for role.
+func (p *CommandPolicy) Check(role string, command string) error {
+	command = strings.TrimSpace(command)
+	for _, r := range p.deny {
+		if r.re.MatchString(command) {
+			return &CommandDeniedError{Command: command, Role: role, Rule: r.pattern}
+		}
+	}
+	for _, r := range p.allow {
+		if r.re.MatchString(command) {
+			return nil
+		}
+	}
+	return &CommandDeniedError{Command: command, Role: role}
+}
+
+// compileRules converts glob patterns into anchored regular expressions.
+func compileRules(patterns []string) []rule {
+	rules := make([]rule, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+		rules = append(rules, rule{
+			pattern: pattern,
+			re:      regexp.MustCompile(`(?is)^` + expr + `$`),
+		})
+	}
+	return rules
+}
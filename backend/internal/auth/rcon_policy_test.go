@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCommandPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		command string
+		allowed bool
+		rule    string
+	}{
+		{name: "no rules deny everything", command: "/players"},
+		{name: "allow match", allow: []string{"/kick *"}, command: "/kick griefer", allowed: true},
+		{name: "allow requires a match", allow: []string{"/kick *"}, command: "/ban griefer"},
+		{name: "allow pattern is anchored", allow: []string{"/kick *"}, command: "/c /kick x"},
+		{name: "bare command does not match pattern with argument", allow: []string{"/kick *"}, command: "/kick"},
+		{name: "case insensitive", allow: []string{"/kick *"}, command: "/KICK griefer", allowed: true},
+		{name: "surrounding whitespace ignored", allow: []string{"/kick *"}, command: "  /kick griefer \n", allowed: true},
+		{name: "deny wins over allow", allow: []string{"*"}, deny: []string{"/c *"}, command: "/c game.print(1)", rule: "/c *"},
+		{name: "deny only", deny: []string{"/sc *"}, command: "/sc x", rule: "/sc *"},
+		{name: "deny only denies others", deny: []string{"/sc *"}, command: "/players"},
+		{name: "allow all with deny passes others", allow: []string{"*"}, deny: []string{"/sc *"}, command: "/players", allowed: true},
+		{name: "wildcard spans lines", allow: []string{"/w *"}, deny: []string{"*/c*"}, command: "/w a\n/c game.print(1)", rule: "*/c*"},
+		{name: "regexp metacharacters are literal", allow: []string{"/help?"}, command: "/helpx"},
+		{name: "empty patterns are ignored", allow: []string{" ", "/admins"}, command: "/admins", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewCommandPolicy(tt.allow, tt.deny).Check(RoleModerator, tt.command)
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("Check() = %v, want allowed", err)
+				}
+				return
+			}
+			var denied *CommandDeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("Check() = %v, want CommandDeniedError", err)
+			}
+			if denied.Rule != tt.rule {
+				t.Errorf("Rule = %q, want %q", denied.Rule, tt.rule)
+			}
+		})
+	}
+}
+
+func TestDefaultCommandPolicy(t *testing.T) {
+	tests := []struct {
+		role    string
+		command string
+		allowed bool
+	}{
+		{RoleOwner, "/c game.print(1)", true},
+		{RoleOperator, "/sc game.print(1)", true},
+		{RoleModerator, "/kick griefer", true},
+		{RoleModerator, "/shout hello", true},
+		{RoleModerator, "/players online", true},
+		{RoleModerator, "/c game.print(1)", false},
+		{RoleModerator, "/promote griefer", false},
+		{RoleViewer, "/players", false},
+		{"unknown", "/players", false},
+		{"", "/players", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.command, func(t *testing.T) {
+			err := DefaultCommandPolicy(tt.role).Check(tt.role, tt.command)
+			if allowed := err == nil; allowed != tt.allowed {
+				t.Errorf("Check() = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
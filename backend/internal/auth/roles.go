@@ -0,0 +1,62 @@
+package auth
+
+// The roles FSM admins can hold and the permissions each role grants on the
+// REST API.
+
+// Roles an FSM admin can be assigned.
+const (
+	RoleModerator = "moderator"
+	RoleOperator  = "operator"
+	RoleOwner     = "owner"
+	RoleViewer    = "viewer"
+)
+
+// DefaultRole is assumed for admins without an explicit role.
+const DefaultRole = RoleOwner
+
+// Permission guards a group of routes.
+type Permission string
+
+const (
+	PermAdmins         Permission = "admins"          // Manage FSM admins and read the audit trail
+	PermFactorioAdmins Permission = "factorio_admins" // Manage the in-game Factorio admin list
+	PermMods           Permission = "mods"            // Download, install and toggle mods
+	PermPlayers        Permission = "players"         // Manage Factorio bans and whitelist
+	PermRCon           Permission = "rcon"            // Send RCON commands, subject to the command policy
+	PermSaves          Permission = "saves"           // Upload, download, delete and select saves
+	PermServerControl  Permission = "server_control"  // Start and stop the server
+	PermSettings       Permission = "settings"        // Read and change Factorio and FSM settings
+	PermVersions       Permission = "versions"        // Download, select and uninstall server versions
+	PermView           Permission = "view"            // Read status, logs and player information
+)
+
+// rolePermissions lists the permissions granted to each role. Factorio admins may
+// run Lua in game, so only roles trusted with unrestricted RCON may appoint them.
+var rolePermissions = map[string][]Permission{
+	RoleOwner: {
+		PermAdmins, PermFactorioAdmins, PermMods, PermPlayers, PermRCon, PermSaves,
+		PermServerControl, PermSettings, PermVersions, PermView,
+	},
+	RoleOperator: {
+		PermFactorioAdmins, PermMods, PermPlayers, PermRCon, PermSaves,
+		PermServerControl, PermSettings, PermVersions, PermView,
+	},
+	RoleModerator: {PermPlayers, PermRCon, PermView},
+	RoleViewer:    {PermView},
+}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants perm.
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package auth
+
+// Signed, expiring session tokens for FSM admins, with a server-side record of
+// the sessions so they can be revoked.
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSession is returned for tokens that are malformed, forged, expired or revoked.
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+// Session is an authenticated login of an FSM admin.
+type Session struct {
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	ID      string    `json:"id"`
+	IP      string    `json:"ip,omitempty"`
+	User    string    `json:"user"`
+}
+
+// tokenPayload is the signed part of a session token.
+type tokenPayload struct {
+	Expires int64  `json:"exp"`
+	ID      string `json:"id"`
+	User    string `json:"user"`
+}
+
+// SessionStore issues session tokens and tracks which of them are still valid.
+// Sessions are persisted so logins survive a restart of FSM.
+type SessionStore struct {
+	mu       sync.Mutex
+	path     string
+	secret   []byte
+	sessions map[string]*Session
+	ttl      time.Duration
+}
+
+// GenerateSecret returns a random hex encoded secret suitable for signing session tokens.
+func GenerateSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// OpenSessionStore loads the sessions persisted at path. Tokens are signed with
+// secret and are valid for ttl after login.
+func OpenSessionStore(path, secret string, ttl time.Duration) (*SessionStore, error) {
+	store := &SessionStore{
+		path:     path,
+		secret:   []byte(secret),
+		sessions: map[string]*Session{},
+		ttl:      ttl,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	now := time.Now()
+	for _, session := range sessions {
+		if session.Expires.After(now) {
+			store.sessions[session.ID] = session
+		}
+	}
+	return store, nil
+}
+
+// Create starts a new session for user and returns its token.
+func (s *SessionStore) Create(user, ip string) (string, Session, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", Session{}, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		Created: now,
+		Expires: now.Add(s.ttl),
+		ID:      hex.EncodeToString(raw),
+		IP:      ip,
+		User:    user,
+	}
+	token, err := s.sign(tokenPayload{Expires: session.Expires.Unix(), ID: session.ID, User: user})
+	if err != nil {
+		return "", Session{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	s.sessions[session.ID] = session
+	return token, *session, s.save()
+}
+
+// Validate checks the signature and expiry of token and that its session has
+// not been revoked.
+func (s *SessionStore) Validate(token string) (Session, error) {
+	payload, err := s.verify(token)
+	if err != nil {
+		return Session{}, err
+	}
+	if time.Now().Unix() >= payload.Expires {
+		return Session{}, ErrInvalidSession
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[payload.ID]
+	if !ok || session.User != payload.User {
+		return Session{}, ErrInvalidSession
+	}
+	return *session, nil
+}
+
+// Revoke ends the session identified by token.
+func (s *SessionStore) Revoke(token string) error {
+	payload, err := s.verify(token)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[payload.ID]; !ok {
+		return nil
+	}
+	delete(s.sessions, payload.ID)
+	return s.save()
+}
+
+// RevokeUser ends every session of user, e.g. after a password change.
+func (s *SessionStore) RevokeUser(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revoked := false
+	for id, session := range s.sessions {
+		if session.User == user {
+			delete(s.sessions, id)
+			revoked = true
+		}
+	}
+	if !revoked {
+		return nil
+	}
+	return s.save()
+}
+
+// sign encodes payload and appends its HMAC-SHA256 signature.
+func (s *SessionStore) sign(payload tokenPayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// verify checks the signature of token and decodes its payload.
+func (s *SessionStore) verify(token string) (tokenPayload, error) {
+	var payload tokenPayload
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return payload, ErrInvalidSession
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(encoded)) {
+		return payload, ErrInvalidSession
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return payload, ErrInvalidSession
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, ErrInvalidSession
+	}
+	return payload, nil
+}
+
+func (s *SessionStore) mac(data string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// prune drops expired sessions. Callers must hold s.mu.
+func (s *SessionStore) prune(now time.Time) {
+	for id, session := range s.sessions {
+		if !session.Expires.After(now) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// save writes the sessions to disk atomically. Callers must hold s.mu.
+func (s *SessionStore) save() error {
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
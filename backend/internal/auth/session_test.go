@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSessionStore(t *testing.T, secret string, ttl time.Duration) *SessionStore {
+	t.Helper()
+	store, err := OpenSessionStore(filepath.Join(t.TempDir(), "sessions.json"), secret, ttl)
+	if err != nil {
+		t.Fatalf("OpenSessionStore() = %v", err)
+	}
+	return store
+}
+
+func TestSessionValidate(t *testing.T) {
+	store := newTestSessionStore(t, "secret", time.Hour)
+	token, session, err := store.Create("alice", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	forged := func(payload tokenPayload) string {
+		data, _ := json.Marshal(payload)
+		return base64.RawURLEncoding.EncodeToString(data) + "." + signature
+	}
+	resigned := func(payload tokenPayload) string {
+		token, err := store.sign(payload)
+		if err != nil {
+			t.Fatalf("sign() = %v", err)
+		}
+		return token
+	}
+	otherStore := newTestSessionStore(t, "other secret", time.Hour)
+	otherToken, _, err := otherStore.Create("alice", "")
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "valid", token: token, valid: true},
+		{name: "empty", token: ""},
+		{name: "no signature", token: encoded},
+		{name: "garbage", token: "not.a-token"},
+		{name: "truncated signature", token: token[:len(token)-4]},
+		{name: "tampered signature", token: encoded + "." + strings.Repeat("A", len(signature))},
+		{name: "tampered user", token: forged(tokenPayload{Expires: session.Expires.Unix(), ID: session.ID, User: "mallory"})},
+		{name: "tampered expiry", token: forged(tokenPayload{Expires: session.Expires.Add(time.Hour).Unix(), ID: session.ID, User: "alice"})},
+		{name: "signed with another secret", token: otherToken},
+		{name: "expired", token: resigned(tokenPayload{Expires: time.Now().Add(-time.Second).Unix(), ID: session.ID, User: "alice"})},
+		{name: "unknown session", token: resigned(tokenPayload{Expires: session.Expires.Unix(), ID: "unknown", User: "alice"})},
+		{name: "user of another session", token: resigned(tokenPayload{Expires: session.Expires.Unix(), ID: session.ID, User: "mallory"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Validate(tt.token)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidSession) {
+					t.Fatalf("Validate() = %v, want ErrInvalidSession", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if got.User != "alice" || got.ID != session.ID {
+				t.Errorf("Validate() = %+v, want session %s of alice", got, session.ID)
+			}
+		})
+	}
+}
+
+func TestSessionExpiredByTTL(t *testing.T) {
+	store := newTestSessionStore(t, "secret", -time.Second)
+	token, _, err := store.Create("alice", "")
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if _, err := store.Validate(token); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Validate() = %v, want ErrInvalidSession", err)
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	store := newTestSessionStore(t, "secret", time.Hour)
+	first, _, _ := store.Create("alice", "")
+	second, _, _ := store.Create("alice", "")
+	other, _, _ := store.Create("bob", "")
+
+	if err := store.Revoke(first); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	if _, err := store.Validate(first); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("revoked token: Validate() = %v, want ErrInvalidSession", err)
+	}
+	if _, err := store.Validate(second); err != nil {
+		t.Errorf("other token of user: Validate() = %v", err)
+	}
+
+	if err := store.RevokeUser("alice"); err != nil {
+		t.Fatalf("RevokeUser() = %v", err)
+	}
+	if _, err := store.Validate(second); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("after RevokeUser: Validate() = %v, want ErrInvalidSession", err)
+	}
+	if _, err := store.Validate(other); err != nil {
+		t.Errorf("token of other user: Validate() = %v", err)
+	}
+}
+
+func TestSessionsSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := OpenSessionStore(path, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("OpenSessionStore() = %v", err)
+	}
+	token, _, err := store.Create("alice", "")
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+
+	reopened, err := OpenSessionStore(path, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("OpenSessionStore() = %v", err)
+	}
+	if _, err := reopened.Validate(token); err != nil {
+		t.Errorf("Validate() after reopen = %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step := tt.unix / int64(totpPeriod.Seconds())
+		if got := totpCode(key, step); got != tt.want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+		step, ok := ValidateTOTP(rfc6238Secret, tt.want, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("ValidateTOTP(T=%d, %s) rejected the code", tt.unix, tt.want)
+		} else if want := tt.unix / 30; step != want {
+			t.Errorf("ValidateTOTP(T=%d) step = %d, want %d", tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// 1111111109 falls in step 37037036, whose code is 081804.
+	now := time.Unix(1111111109, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		step   int64
+		valid  bool
+	}{
+		{name: "current step", secret: rfc6238Secret, code: "081804", at: now, step: 37037036, valid: true},
+		{name: "previous step", secret: rfc6238Secret, code: "081804", at: now.Add(totpPeriod), step: 37037036, valid: true},
+		{name: "next step", secret: rfc6238Secret, code: "081804", at: now.Add(-totpPeriod), step: 37037036, valid: true},
+		{name: "two steps late", secret: rfc6238Secret, code: "081804", at: now.Add(2 * totpPeriod)},
+		{name: "two steps early", secret: rfc6238Secret, code: "081804", at: now.Add(-2 * totpPeriod)},
+		{name: "wrong code", secret: rfc6238Secret, code: "081805", at: now},
+		{name: "spaces and lower case secret", secret: " " + strings.ToLower(rfc6238Secret) + " ", code: " 081 804 ", at: now, step: 37037036, valid: true},
+		{name: "too short", secret: rfc6238Secret, code: "81804", at: now},
+		{name: "too long", secret: rfc6238Secret, code: "0818040", at: now},
+		{name: "empty", secret: rfc6238Secret, code: "", at: now},
+		{name: "invalid secret", secret: "not base32!", code: "081804", at: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, tt.at)
+			if ok != tt.valid {
+				t.Fatalf("ValidateTOTP() = %v, want %v", ok, tt.valid)
+			}
+			if step != tt.step {
+				t.Errorf("step = %d, want %d", step, tt.step)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() = %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+	now := time.Now()
+	step := now.Unix() / int64(totpPeriod.Seconds())
+	if _, ok := ValidateTOTP(secret, totpCode(key, step), now); !ok {
+		t.Error("ValidateTOTP() rejected the current code")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(TOTPProvisioningURI("Factorio Server Manager", "alice", rfc6238Secret))
+	if err != nil {
+		t.Fatalf("url.Parse() = %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Factorio Server Manager:alice" {
+		t.Errorf("TOTPProvisioningURI() = %s", uri)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{"secret": rfc6238Secret, "algorithm": "SHA1", "digits": "6", "period": "30"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() = %v", err)
+	}
+	if len(codes) != 3 || len(hashes) != 3 {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes and %d hashes, want 3", len(codes), len(hashes))
+	}
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted xxxxx-xxxxx", code)
+		}
+		if hashes[i] == code || strings.Contains(hashes[i], strings.ReplaceAll(code, "-", "")) {
+			t.Errorf("hash of %q contains the code", code)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		code  string
+		valid bool
+	}{
+		{name: "exact", code: codes[0], valid: true},
+		{name: "reused", code: codes[0]},
+		{name: "upper case without dash", code: strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), valid: true},
+		{name: "surrounding whitespace", code: " " + codes[2] + " ", valid: true},
+		{name: "unknown", code: "aaaaa-aaaaa"},
+		{name: "empty", code: ""},
+	}
+	remaining := hashes
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, ok := UseRecoveryCode(remaining, tt.code)
+			if ok != tt.valid {
+				t.Fatalf("UseRecoveryCode() = %v, want %v", ok, tt.valid)
+			}
+			want := len(remaining)
+			if tt.valid {
+				want--
+			}
+			if len(left) != want {
+				t.Errorf("%d codes left, want %d", len(left), want)
+			}
+			remaining = left
+		})
+	}
+	if len(remaining) != 0 {
+		t.Errorf("%d codes left after using all, want 0", len(remaining))
+	}
+	if _, ok := UseRecoveryCode(hashes, codes[0]); !ok {
+		t.Error("UseRecoveryCode() modified the stored hashes")
+	}
+}
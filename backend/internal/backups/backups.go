@@ -0,0 +1,260 @@
+// Package backups keeps timestamped copies of Factorio save files in a backups
+// directory, restores them into the saves directory and prunes them according
+// to a tiered retention policy.
+package backups
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeLayout is the timestamp embedded in backup file names.
+const timeLayout = "20060102T150405Z"
+
+// Reasons recorded in the name of a backup.
+const (
+	ReasonManual    = "manual"
+	ReasonRestore   = "restore"
+	ReasonScheduled = "scheduled"
+	ReasonStart     = "start"
+	ReasonStop      = "stop"
+	ReasonVersion   = "version"
+)
+
+// ErrNotFound is returned when a named backup does not exist.
+var ErrNotFound = errors.New("backup not found")
+
+// Backup describes a single snapshot of a save file.
+type Backup struct {
+	Created time.Time `json:"created"`
+	Name    string    `json:"name"`
+	Reason  string    `json:"reason"`
+	Save    string    `json:"save"`
+	Size    int64     `json:"size"`
+}
+
+// Retention describes which backups of a save are kept. A backup is kept when
+// any rule selects it; when every rule is zero nothing is pruned.
+type Retention struct {
+	KeepDaily  int // Newest backup of each of the last N days that have one
+	KeepHourly int // Newest backup of each of the last N hours that have one
+	KeepLast   int // The N most recent backups
+	KeepWeekly int // Newest backup of each of the last N ISO weeks that have one
+}
+
+// Snapshot copies the save file at savePath into dir. The copy is written to a
+// temporary file first so a partially written backup is never listed.
+func Snapshot(dir string, savePath string, reason string, now time.Time) (Backup, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Backup{}, err
+	}
+
+	save := strings.TrimSuffix(filepath.Base(savePath), ".zip")
+	created := now.UTC().Truncate(time.Second)
+	name := fmt.Sprintf("%s_%s_%s.zip", save, created.Format(timeLayout), reason)
+	size, err := copyFile(savePath, filepath.Join(dir, name))
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{Created: created, Name: name, Reason: reason, Save: save + ".zip", Size: size}, nil
+}
+
+// List returns the backups in dir, newest first. A missing directory is treated
+// as empty.
+func List(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]Backup, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		backup, ok := parseName(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backup.Size = info.Size()
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].Created.Equal(backups[j].Created) {
+			return backups[i].Name > backups[j].Name
+		}
+		return backups[i].Created.After(backups[j].Created)
+	})
+	return backups, nil
+}
+
+// Get returns the named backup in dir and its path.
+func Get(dir string, name string) (Backup, string, error) {
+	backup, ok := parseName(name)
+	if !ok || name != filepath.Base(name) {
+		return Backup{}, "", ErrNotFound
+	}
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return Backup{}, "", ErrNotFound
+	}
+	if err != nil {
+		return Backup{}, "", err
+	}
+	backup.Size = info.Size()
+	return backup, path, nil
+}
+
+// Restore copies the named backup in dir back into savesDir under the name of
+// the save it was taken from, replacing that save. It returns the restored backup.
+func Restore(dir string, name string, savesDir string) (Backup, error) {
+	backup, path, err := Get(dir, name)
+	if err != nil {
+		return Backup{}, err
+	}
+	if _, err := copyFile(path, filepath.Join(savesDir, backup.Save)); err != nil {
+		return Backup{}, err
+	}
+	return backup, nil
+}
+
+// Prune deletes the backups in dir that are not selected by the retention policy.
+// Each save is pruned independently, so backups of one save never push out
+// backups of another.
+func Prune(dir string, retention Retention) error {
+	if retention.KeepLast <= 0 && retention.KeepHourly <= 0 && retention.KeepDaily <= 0 && retention.KeepWeekly <= 0 {
+		return nil
+	}
+
+	all, err := List(dir)
+	if err != nil {
+		return err
+	}
+
+	bySave := map[string][]Backup{}
+	for _, b := range all {
+		bySave[b.Save] = append(bySave[b.Save], b)
+	}
+
+	for _, list := range bySave {
+		keep := selectKept(list, retention)
+		for _, b := range list {
+			if keep[b.Name] {
+				continue
+			}
+			path := filepath.Join(dir, b.Name)
+			if err := os.Remove(path); err != nil {
+				log.Printf("Failed to prune %s: %v\n", path, err)
+				continue
+			}
+			log.Printf("Pruned backup %s\n", path)
+		}
+	}
+	return nil
+}
+
+// selectKept returns the names of the backups kept by retention. list must be
+// sorted newest first.
+func selectKept(list []Backup, retention Retention) map[string]bool {
+	keep := map[string]bool{}
+	for i := 0; i < len(list) && i < retention.KeepLast; i++ {
+		keep[list[i].Name] = true
+	}
+
+	tiers := []struct {
+		count  int
+		bucket func(time.Time) string
+	}{
+		{retention.KeepHourly, func(t time.Time) string { return t.Format("2006010215") }},
+		{retention.KeepDaily, func(t time.Time) string { return t.Format("20060102") }},
+		{retention.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+	}
+	for _, tier := range tiers {
+		seen := map[string]bool{}
+		for _, b := range list {
+			if len(seen) >= tier.count {
+				break
+			}
+			bucket := tier.bucket(b.Created.Local())
+			if seen[bucket] {
+				continue
+			}
+			seen[bucket] = true
+			keep[b.Name] = true
+		}
+	}
+	return keep
+}
+
+// parseName extracts the save, time and reason from a backup file name of the
+// form <save>_<time>_<reason>.zip.
+func parseName(name string) (Backup, bool) {
+	base, ok := strings.CutSuffix(name, ".zip")
+	if !ok {
+		return Backup{}, false
+	}
+	i := strings.LastIndex(base, "_")
+	if i < 0 {
+		return Backup{}, false
+	}
+	rest, reason := base[:i], base[i+1:]
+	j := strings.LastIndex(rest, "_")
+	if j <= 0 {
+		return Backup{}, false
+	}
+	created, err := time.Parse(timeLayout, rest[j+1:])
+	if err != nil {
+		return Backup{}, false
+	}
+	return Backup{Created: created, Name: name, Reason: reason, Save: rest[:j] + ".zip"}, true
+}
+
+// copyFile copies src to dst through a temporary file in the destination
+// directory, so dst is either replaced entirely or left untouched.
+func copyFile(src string, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-"+filepath.Base(dst))
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, in)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,181 @@
+package backups
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// at returns a time in the local zone, which selectKept buckets by.
+func at(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.Local)
+}
+
+func TestSelectKept(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention Retention
+		created   []time.Time // Newest first
+		want      []int       // Indexes into created
+	}{
+		{
+			name:    "nothing retained",
+			created: []time.Time{at(2024, 1, 8, 12, 30), at(2024, 1, 8, 11, 30)},
+		},
+		{
+			name:      "last",
+			retention: Retention{KeepLast: 2},
+			created:   []time.Time{at(2024, 1, 8, 12, 30), at(2024, 1, 8, 12, 20), at(2024, 1, 8, 12, 10)},
+			want:      []int{0, 1},
+		},
+		{
+			name:      "last exceeding backups",
+			retention: Retention{KeepLast: 5},
+			created:   []time.Time{at(2024, 1, 8, 12, 30), at(2024, 1, 8, 12, 20)},
+			want:      []int{0, 1},
+		},
+		{
+			name:      "hourly keeps newest of each hour",
+			retention: Retention{KeepHourly: 2},
+			created:   []time.Time{at(2024, 1, 8, 12, 30), at(2024, 1, 8, 12, 0), at(2024, 1, 8, 11, 59), at(2024, 1, 8, 11, 0), at(2024, 1, 8, 10, 59)},
+			want:      []int{0, 2},
+		},
+		{
+			name:      "hourly counts hours that have a backup",
+			retention: Retention{KeepHourly: 2},
+			created:   []time.Time{at(2024, 1, 8, 12, 30), at(2024, 1, 7, 9, 0), at(2024, 1, 6, 9, 0)},
+			want:      []int{0, 1},
+		},
+		{
+			name:      "daily splits at midnight",
+			retention: Retention{KeepDaily: 2},
+			created:   []time.Time{at(2024, 1, 8, 0, 0), at(2024, 1, 7, 23, 59), at(2024, 1, 7, 8, 0), at(2024, 1, 6, 12, 0)},
+			want:      []int{0, 1},
+		},
+		{
+			name:      "weekly splits at monday",
+			retention: Retention{KeepWeekly: 2},
+			created:   []time.Time{at(2024, 1, 8, 0, 0), at(2024, 1, 7, 23, 59), at(2024, 1, 1, 0, 0), at(2023, 12, 31, 23, 59)},
+			want:      []int{0, 1},
+		},
+		{
+			name:      "weekly uses iso years",
+			retention: Retention{KeepWeekly: 3},
+			created:   []time.Time{at(2025, 1, 5, 12, 0), at(2024, 12, 30, 0, 0), at(2024, 12, 29, 23, 59), at(2024, 12, 22, 23, 59)},
+			want:      []int{0, 2, 3},
+		},
+		{
+			name:      "tiers combine",
+			retention: Retention{KeepLast: 1, KeepHourly: 1, KeepDaily: 2, KeepWeekly: 2},
+			created: []time.Time{
+				at(2024, 1, 8, 12, 30), at(2024, 1, 8, 12, 10), at(2024, 1, 8, 9, 0),
+				at(2024, 1, 7, 18, 0), at(2024, 1, 7, 6, 0), at(2024, 1, 2, 6, 0),
+			},
+			want: []int{0, 3},
+		},
+		{
+			name:      "tiers pick from the same backups",
+			retention: Retention{KeepLast: 2, KeepDaily: 3},
+			created:   []time.Time{at(2024, 1, 8, 12, 30), at(2024, 1, 8, 12, 10), at(2024, 1, 7, 18, 0), at(2024, 1, 7, 6, 0), at(2024, 1, 5, 6, 0)},
+			want:      []int{0, 1, 2, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := make([]Backup, len(tt.created))
+			for i, created := range tt.created {
+				list[i] = Backup{Created: created.UTC(), Name: created.UTC().Format(timeLayout)}
+			}
+
+			keep := selectKept(list, tt.retention)
+			var got []int
+			for i, b := range list {
+				if keep[b.Name] {
+					got = append(got, i)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseName(t *testing.T) {
+	created := time.Date(2024, 1, 8, 12, 30, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		want Backup
+		ok   bool
+	}{
+		{name: "world_20240108T123005Z_manual.zip", want: Backup{Created: created, Reason: "manual", Save: "world.zip"}, ok: true},
+		{name: "my_world_20240108T123005Z_stop.zip", want: Backup{Created: created, Reason: "stop", Save: "my_world.zip"}, ok: true},
+		{name: "world_20240108T123005Z_manual"},
+		{name: "world_manual.zip"},
+		{name: "_20240108T123005Z_manual.zip"},
+		{name: "world_2024-01-08_manual.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseName(tt.name)
+			if ok != tt.ok {
+				t.Fatalf("parseName() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			tt.want.Name = tt.name
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseName() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	save := filepath.Join(t.TempDir(), "world.zip")
+	other := filepath.Join(t.TempDir(), "other.zip")
+	for _, path := range []string{save, other} {
+		if err := os.WriteFile(path, []byte("save"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 0; i < 3; i++ {
+		b, err := Snapshot(dir, save, ReasonScheduled, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("Snapshot() = %v", err)
+		}
+		if i > 0 {
+			want = append(want, b.Name)
+		}
+	}
+	b, err := Snapshot(dir, other, ReasonManual, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	want = append(want, b.Name)
+
+	if err := Prune(dir, Retention{KeepLast: 2}); err != nil {
+		t.Fatalf("Prune() = %v", err)
+	}
+	list, err := List(dir)
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	var got []string
+	for _, b := range list {
+		got = append(got, b.Name)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after Prune: %v, want %v", got, want)
+	}
+}
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"gopkg.in/ini.v1"
 )
@@ -20,13 +23,17 @@ type FactorioConfig struct {
 	ConfigDir       string        `ini:"config" default:"./config"`           // Path to config directory
 	Downloads       string        `ini:"downloads"`                           // Path to download directory
 	Files           FactorioFiles `ini:"-"`                                   // Derived file paths (not persisted)
+	LogHistory      int           `ini:"log_history" default:"1000"`          // Number of recent log lines kept in memory for replay
 	LogsDir         string        `ini:"logs" default:"./logs"`               // Path to logs directory
 	ModsDir         string        `ini:"mods" default:"./mods"`               // Path to mods directory
 	SavesDir        string        `ini:"saves" default:"./saves"`             // Path to saves directory
 	Save            string        `ini:"save"`                                // Name of the active save file
+	SaveOnStop      bool          `ini:"save_on_stop"`                        // Whether to issue /server-save over RCON before stopping
 	SelectedBranch  string        `ini:"branch"`                              // Selected branch (e.g. stable/experimental)
 	SelectedVersion string        `ini:"version"`                             // Selected version string
 	ServerVersions  string        `ini:"server_versions" default:"./servers"` // Path to downloaded server versions
+	StopMessage     string        `ini:"stop_message"`                        // Message announced to players before stopping
+	StopTimeout     int           `ini:"stop_timeout" default:"30"`           // Seconds to wait for exit before sending SIGKILL
 	Token           string        `ini:"token"`                               // Your factorio.com account API token (https://factorio.com/profile)
 	Username        string        `ini:"username"`                            // Your factorio.com account username
 }
@@ -35,19 +42,63 @@ type FactorioConfig struct {
 type FactorioFiles struct {
 	AdminList      string // Path to server-adminlist.json
 	BanList        string // Path to server-banlist.json
+	MapGenSettings string // Path to map-gen-settings.json
+	MapSettings    string // Path to map-settings.json
 	ServerId       string // Path to server-id.json
 	ServerSettings string // Path to server-settings.json
 	WhiteList      string // Path to server-whitelist.json
 }
 
-// FSMConfig contains all configuration used by the application.
+// FSMConfig contains all configuration used by the application. Admins, Roles,
+// TOTP and RecoveryCodes change at runtime and must only be accessed through the
+// admin accessors, ViewAdmins and UpdateAdmins once the REST server is running.
 type FSMConfig struct {
-	Admins   map[string]string // Admin usernames and password hashes
-	Factorio FactorioConfig    // Factorio configuration
-	Path     string            // Path to the loaded config file
-	RCon     RConConfig        // RCON configuration
-	Server   ServerConfig      // HTTP server configuration
-	file     *ini.File         // Internal INI file reference
+	Admins        map[string]string     // Admin usernames and password hashes
+	Audit         AuditConfig           // Audit log rotation
+	Backups       BackupsConfig         // Save backup schedule and retention
+	Factorio      FactorioConfig        // Factorio configuration
+	Login         LoginConfig           // Login throttling
+	Logs          LogsConfig            // Console log retention
+	Path          string                // Path to the loaded config file
+	RCon          RConConfig            // RCON configuration
+	RConPolicies  map[string]RConPolicy // RCON command policies keyed by role
+	RecoveryCodes map[string][]string   // Admin usernames and hashes of their unused TOTP recovery codes
+	Restart       RestartConfig         // Automatic restart policy
+	Roles         map[string]string     // Admin usernames and their roles
+	Server        ServerConfig          // HTTP server configuration
+	TOTP          map[string]string     // Admin usernames and their TOTP secrets
+	file          *ini.File             // Internal INI file reference
+	mu            sync.RWMutex          // Guards the admin maps and writes to file
+}
+
+// AuditConfig holds the audit log rotation policy from the [audit] section.
+type AuditConfig struct {
+	MaxFiles int `ini:"max_files"` // Rotated audit log files to keep
+	MaxSize  int `ini:"max_size"`  // Megabytes at which the audit log is rotated, 0 disables rotation
+}
+
+// BackupsConfig holds the save backup schedule and retention policy from the
+// [backups] section.
+type BackupsConfig struct {
+	Dir        string `ini:"dir"`         // Path where save backups are kept
+	Interval   int    `ini:"interval"`    // Minutes between backups while the server runs, 0 disables scheduled backups
+	KeepDaily  int    `ini:"keep_daily"`  // Days for which the newest backup is kept
+	KeepHourly int    `ini:"keep_hourly"` // Hours for which the newest backup is kept
+	KeepLast   int    `ini:"keep_last"`   // Most recent backups always kept
+	KeepWeekly int    `ini:"keep_weekly"` // Weeks for which the newest backup is kept
+}
+
+// LoginConfig holds the login throttling policy from the [login] section.
+type LoginConfig struct {
+	DelayAfter      int `ini:"delay_after"`      // Failed attempts before waits between attempts are enforced
+	LockoutAfter    int `ini:"lockout_after"`    // Failed attempts before the IP or username is locked out, 0 disables lockouts
+	LockoutDuration int `ini:"lockout_duration"` // Seconds a lockout lasts
+}
+
+// LogsConfig holds the console log retention policy from the [logs] section.
+type LogsConfig struct {
+	MaxAge  int `ini:"max_age"`  // Days to keep console logs, 0 keeps them forever
+	MaxSize int `ini:"max_size"` // Megabytes of console logs to keep, 0 for no limit
 }
 
 // RConConfig holds configuration for the RCON remote console.
@@ -57,9 +108,52 @@ type RConConfig struct {
 	Password string `ini:"password"`                       // RCON password
 }
 
+// RConPolicy holds the RCON command patterns from an [rcon_policy.<role>] section.
+type RConPolicy struct {
+	Allow []string `ini:"allow" delim:","` // Patterns of commands the role may run
+	Deny  []string `ini:"deny" delim:","`  // Patterns of commands the role may never run
+}
+
+// RestartConfig holds the automatic restart policy from the [restart] section.
+type RestartConfig struct {
+	BackoffMax int    `ini:"backoff_max" default:"300"` // Upper bound in seconds for the delay between restarts
+	BackoffMin int    `ini:"backoff_min" default:"5"`   // Delay in seconds before the first restart, doubled on each retry
+	MaxRetries int    `ini:"max_retries" default:"5"`   // Consecutive restarts attempted before giving up
+	Policy     string `ini:"policy" default:"never"`    // One of never, on-failure or always
+	ResetAfter int    `ini:"reset_after" default:"600"` // Seconds of uptime after which the retry counter resets
+}
+
+// Restart policies supported by RestartConfig.Policy.
+const (
+	RestartAlways    = "always"
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+)
+
 // ServerConfig holds HTTP server configuration.
 type ServerConfig struct {
-	Listen string `ini:"listen"` // Listen address for the HTTP server
+	AllowedOrigins []string `ini:"allowed_origins" delim:","`     // Origins allowed to make cross-origin and WebSocket requests
+	BasicAuth      bool     `ini:"basic_auth"`                    // Whether HTTP Basic credentials are accepted alongside session tokens
+	DataDir        string   `ini:"data" default:"./data/fsm"`     // Path where FSM keeps its own state
+	Listen         string   `ini:"listen"`                        // Listen address for the HTTP server
+	SessionSecret  string   `ini:"session_secret"`                // Key used to sign session tokens, generated when empty
+	RedirectHTTP   string   `ini:"redirect_http"`                 // Listen address of a plain HTTP listener redirecting to HTTPS, empty to disable
+	SessionTTL     int      `ini:"session_ttl" default:"43200"`   // Lifetime of a session token in seconds
+	TLSCert        string   `ini:"tls_cert"`                      // Path to the PEM certificate chain, enables HTTPS together with tls_key
+	TLSKey         string   `ini:"tls_key"`                       // Path to the PEM private key
+	TLSMinVersion  string   `ini:"tls_min_version" default:"1.2"` // Minimum TLS version, 1.2 or 1.3
+	TrustedProxies []string `ini:"trusted_proxies" delim:","`     // Reverse proxy IPs or CIDRs whose X-Forwarded-For is trusted
+}
+
+// Minimum TLS versions supported by ServerConfig.TLSMinVersion.
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// TLSEnabled reports whether the HTTP server should serve HTTPS.
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
 }
 
 // Load reads the config from disk and parses it into structured config.
@@ -82,11 +176,50 @@ func Load(configPath *string) (error, *FSMConfig) {
 	factorioConfig.Files = FactorioFiles{
 		AdminList:      fmt.Sprintf("%s/server-adminlist.json", factorioConfig.ConfigDir),
 		BanList:        fmt.Sprintf("%s/server-banlist.json", factorioConfig.ConfigDir),
+		MapGenSettings: fmt.Sprintf("%s/map-gen-settings.json", factorioConfig.ConfigDir),
+		MapSettings:    fmt.Sprintf("%s/map-settings.json", factorioConfig.ConfigDir),
 		ServerId:       fmt.Sprintf("%s/server-id.json", factorioConfig.ConfigDir),
 		ServerSettings: fmt.Sprintf("%s/server-settings.json", factorioConfig.ConfigDir),
 		WhiteList:      fmt.Sprintf("%s/server-whitelist.json", factorioConfig.ConfigDir),
 	}
 
+	auditConfig := AuditConfig{
+		MaxFiles: 5,
+		MaxSize:  10,
+	}
+	if err := cfg.Section("audit").MapTo(&auditConfig); err != nil {
+		return fmt.Errorf("failed to load [audit]: %w", err), nil
+	}
+
+	backupsConfig := BackupsConfig{
+		Dir:        "./data/backups",
+		Interval:   60,
+		KeepDaily:  7,
+		KeepHourly: 24,
+		KeepLast:   10,
+		KeepWeekly: 4,
+	}
+	if err := cfg.Section("backups").MapTo(&backupsConfig); err != nil {
+		return fmt.Errorf("failed to load [backups]: %w", err), nil
+	}
+	if backupsConfig.Dir == "" {
+		backupsConfig.Dir = "./data/backups"
+	}
+
+	loginConfig := LoginConfig{
+		DelayAfter:      3,
+		LockoutAfter:    10,
+		LockoutDuration: 900,
+	}
+	if err := cfg.Section("login").MapTo(&loginConfig); err != nil {
+		return fmt.Errorf("failed to load [login]: %w", err), nil
+	}
+
+	var logsConfig LogsConfig
+	if err := cfg.Section("logs").MapTo(&logsConfig); err != nil {
+		return fmt.Errorf("failed to load [logs]: %w", err), nil
+	}
+
 	var rconConfig RConConfig
 	if cfg.HasSection("rcon") {
 		if err := cfg.Section("rcon").MapTo(&rconConfig); err != nil {
@@ -95,52 +228,204 @@ func Load(configPath *string) (error, *FSMConfig) {
 		rconConfig.Enabled = true
 	}
 
+	restartConfig := RestartConfig{
+		BackoffMax: 300,
+		BackoffMin: 5,
+		MaxRetries: 5,
+		Policy:     RestartNever,
+		ResetAfter: 600,
+	}
+	if err := cfg.Section("restart").MapTo(&restartConfig); err != nil {
+		return fmt.Errorf("failed to load [restart]: %w", err), nil
+	}
+	switch restartConfig.Policy {
+	case RestartAlways, RestartNever, RestartOnFailure:
+	default:
+		return fmt.Errorf("invalid [restart] policy %q", restartConfig.Policy), nil
+	}
+
 	var serverConfig ServerConfig
 	if err := cfg.Section("server").MapTo(&serverConfig); err != nil {
 		serverConfig.Listen = ":8080"
 	}
+	if serverConfig.DataDir == "" {
+		serverConfig.DataDir = "./data/fsm"
+	}
+	if serverConfig.SessionTTL <= 0 {
+		serverConfig.SessionTTL = 43200
+	}
+	if serverConfig.TLSMinVersion == "" {
+		serverConfig.TLSMinVersion = TLSVersion12
+	}
+	if serverConfig.TLSMinVersion != TLSVersion12 && serverConfig.TLSMinVersion != TLSVersion13 {
+		return fmt.Errorf("invalid [server] tls_min_version %q", serverConfig.TLSMinVersion), nil
+	}
+	if (serverConfig.TLSCert == "") != (serverConfig.TLSKey == "") {
+		return errors.New("[server] tls_cert and tls_key must be set together"), nil
+	}
+	for _, origin := range serverConfig.AllowedOrigins {
+		if strings.Contains(origin, "*") {
+			return fmt.Errorf("invalid [server] allowed_origins %q, wildcards would expose sessions to every site", origin), nil
+		}
+	}
+
+	admins := loadKeyValues(cfg, "admins")
+	roles := loadKeyValues(cfg, "roles")
+	totp := loadKeyValues(cfg, "totp")
 
-	admins := map[string]string{}
-	if cfg.HasSection("admins") {
-		for _, key := range cfg.Section("admins").Keys() {
-			admins[key.Name()] = key.Value()
+	recoveryCodes := map[string][]string{}
+	for user, codes := range loadKeyValues(cfg, "recovery_codes") {
+		if codes != "" {
+			recoveryCodes[user] = strings.Split(codes, ",")
 		}
 	}
 
+	policies := map[string]RConPolicy{}
+	for _, section := range cfg.Sections() {
+		role, ok := strings.CutPrefix(section.Name(), "rcon_policy.")
+		if !ok {
+			continue
+		}
+		var policy RConPolicy
+		if err := section.MapTo(&policy); err != nil {
+			return fmt.Errorf("failed to load [%s]: %w", section.Name(), err), nil
+		}
+		policies[role] = policy
+	}
+
 	fsmConfig := FSMConfig{
-		Admins:   admins,
-		Factorio: factorioConfig,
-		Path:     resolvedPath,
-		RCon:     rconConfig,
-		Server:   serverConfig,
-		file:     cfg,
+		Admins:        admins,
+		Audit:         auditConfig,
+		Backups:       backupsConfig,
+		Factorio:      factorioConfig,
+		Login:         loginConfig,
+		Logs:          logsConfig,
+		Path:          resolvedPath,
+		RCon:          rconConfig,
+		RConPolicies:  policies,
+		RecoveryCodes: recoveryCodes,
+		Restart:       restartConfig,
+		Roles:         roles,
+		TOTP:          totp,
+		Server:        serverConfig,
+		file:          cfg,
 	}
 
 	return nil, &fsmConfig
 }
 
+// AdminPasswordHash returns the password hash of an admin.
+func (cfg *FSMConfig) AdminPasswordHash(user string) (string, bool) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	hash, ok := cfg.Admins[user]
+	return hash, ok
+}
+
+// AdminRole returns the role assigned to an admin, if any.
+func (cfg *FSMConfig) AdminRole(user string) (string, bool) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	role, ok := cfg.Roles[user]
+	return role, ok
+}
+
+// AdminTOTPSecret returns the TOTP secret of an admin enrolled in two-factor authentication.
+func (cfg *FSMConfig) AdminTOTPSecret(user string) (string, bool) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	secret, ok := cfg.TOTP[user]
+	return secret, ok
+}
+
+// ViewAdmins calls fn while holding a read lock on Admins, Roles, TOTP and RecoveryCodes.
+func (cfg *FSMConfig) ViewAdmins(fn func()) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	fn()
+}
+
+// UpdateAdmins calls fn while holding the write lock on Admins, Roles, TOTP and
+// RecoveryCodes and, when fn returns nil, writes the config file before releasing
+// it. The error of fn or of writing the file is returned.
+func (cfg *FSMConfig) UpdateAdmins(fn func() error) error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+	return cfg.save()
+}
+
 // SaveToFile writes the current config back to the original config path.
 func (cfg *FSMConfig) SaveToFile() error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.save()
+}
+
+// save writes the config file. The caller must hold cfg.mu.
+func (cfg *FSMConfig) save() error {
 	if err := cfg.file.Section("factorio").ReflectFrom(&cfg.Factorio); err != nil {
 		return fmt.Errorf("failed to write [factorio] config: %w", err)
 	}
+	if err := cfg.file.Section("audit").ReflectFrom(&cfg.Audit); err != nil {
+		return fmt.Errorf("failed to write [audit] config: %w", err)
+	}
+	if err := cfg.file.Section("backups").ReflectFrom(&cfg.Backups); err != nil {
+		return fmt.Errorf("failed to write [backups] config: %w", err)
+	}
+	if err := cfg.file.Section("login").ReflectFrom(&cfg.Login); err != nil {
+		return fmt.Errorf("failed to write [login] config: %w", err)
+	}
+	if err := cfg.file.Section("logs").ReflectFrom(&cfg.Logs); err != nil {
+		return fmt.Errorf("failed to write [logs] config: %w", err)
+	}
 	if err := cfg.file.Section("rcon").ReflectFrom(&cfg.RCon); err != nil {
 		return fmt.Errorf("failed to write [rcon] config: %w", err)
 	}
+	if err := cfg.file.Section("restart").ReflectFrom(&cfg.Restart); err != nil {
+		return fmt.Errorf("failed to write [restart] config: %w", err)
+	}
 	if err := cfg.file.Section("server").ReflectFrom(&cfg.Server); err != nil {
 		return fmt.Errorf("failed to write [server] config: %w", err)
 	}
-	adminSection := cfg.file.Section("admins")
-	for _, key := range adminSection.KeyStrings() {
-		adminSection.DeleteKey(key)
-	}
-	for k, v := range cfg.Admins {
-		adminSection.Key(k).SetValue(v)
+	writeKeyValues(cfg.file, "admins", cfg.Admins)
+	writeKeyValues(cfg.file, "roles", cfg.Roles)
+	writeKeyValues(cfg.file, "totp", cfg.TOTP)
+
+	recoveryCodes := map[string]string{}
+	for user, codes := range cfg.RecoveryCodes {
+		recoveryCodes[user] = strings.Join(codes, ",")
 	}
+	writeKeyValues(cfg.file, "recovery_codes", recoveryCodes)
 
 	return cfg.file.SaveTo(cfg.Path)
 }
 
+// loadKeyValues returns the keys of a section as a map, or an empty map if the
+// section does not exist.
+func loadKeyValues(cfg *ini.File, section string) map[string]string {
+	values := map[string]string{}
+	if cfg.HasSection(section) {
+		for _, key := range cfg.Section(section).Keys() {
+			values[key.Name()] = key.Value()
+		}
+	}
+	return values
+}
+
+// writeKeyValues replaces the keys of a section with values.
+func writeKeyValues(cfg *ini.File, section string, values map[string]string) {
+	sec := cfg.Section(section)
+	for _, key := range sec.KeyStrings() {
+		sec.DeleteKey(key)
+	}
+	for k, v := range values {
+		sec.Key(k).SetValue(v)
+	}
+}
+
 // findConfigPath returns the first found default config path if cliPath is empty.
 func findConfigPath(cliPath string) string {
 	if cliPath != "" {
@@ -0,0 +1,111 @@
+package factorio
+
+// Named map-gen-settings presets, stored in the config directory for use when
+// creating new maps.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snarf-dev/fsm/v2/internal/config"
+)
+
+// mapPresetsDir is the directory inside the config directory holding presets.
+const mapPresetsDir = "map-gen-presets"
+
+// mapPresetName restricts preset names to characters that are safe in file names.
+var mapPresetName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	ErrInvalidMapPresetName = errors.New("preset names may only contain letters, digits, - and _")
+	ErrMapPresetNotFound    = errors.New("map preset not found")
+)
+
+// ListMapPresets returns the names of the stored map-gen presets, sorted.
+func ListMapPresets(cfg *config.FSMConfig) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(cfg.Factorio.ConfigDir, mapPresetsDir))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.Type().IsRegular() && ok && mapPresetName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// MapPresetPath returns the path of the named preset, which must exist.
+func MapPresetPath(cfg *config.FSMConfig, name string) (string, error) {
+	if !mapPresetName.MatchString(name) {
+		return "", ErrInvalidMapPresetName
+	}
+	path := filepath.Join(cfg.Factorio.ConfigDir, mapPresetsDir, name+".json")
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrMapPresetNotFound
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadMapPreset returns the map-gen-settings stored under name.
+func ReadMapPreset(cfg *config.FSMConfig, name string) (map[string]interface{}, error) {
+	path, err := MapPresetPath(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// WriteMapPreset stores map-gen-settings under name, replacing any existing preset.
+func WriteMapPreset(cfg *config.FSMConfig, name string, settings map[string]interface{}) error {
+	if !mapPresetName.MatchString(name) {
+		return ErrInvalidMapPresetName
+	}
+	dir := filepath.Join(cfg.Factorio.ConfigDir, mapPresetsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, name+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name+".json"))
+}
+
+// DeleteMapPreset removes the named preset.
+func DeleteMapPreset(cfg *config.FSMConfig, name string) error {
+	path, err := MapPresetPath(cfg, name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
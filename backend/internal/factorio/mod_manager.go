@@ -2,6 +2,7 @@ package factorio
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/snarf-dev/fsm/v2/internal/config"
 	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/mods"
 )
 
 type ModInfo struct {
@@ -245,3 +247,64 @@ func createModDownloadUrl(cfg *config.FSMConfig, uri string) (string, error) {
 
 	return fmt.Sprintf("https://mods.factorio.com/%s?username=%s&token=%s", uri, username, token), nil
 }
+
+// SyncMods makes the installed mods match a save: required mods that are missing
+// or installed in another version are downloaded and installed in the required
+// version, replacing other versions, and required mods are enabled. Mods the save
+// does not use are disabled only when disableExtra is set; loading a save without
+// a mod it does use removes that mod's content from the map. Failures for one mod
+// do not stop the others.
+func SyncMods(cfg *config.FSMConfig, diff *mods.Diff, disableExtra bool) error {
+	var errs []error
+	install := append([]mods.Mod{}, diff.Missing...)
+	for _, mismatch := range diff.VersionMismatch {
+		install = append(install, mods.Mod{Name: mismatch.Name, Version: mismatch.Required})
+	}
+
+	enable := append([]mods.Mod{}, diff.Disabled...)
+	for _, mod := range install {
+		if _, err := DownloadMod(cfg, mod.Name, mod.Version); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := removeInstalledVersions(cfg, mod.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := InstallMod(cfg, mod.Name, mod.Version); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		enable = append(enable, mod)
+	}
+
+	modList := filepath.Join(cfg.Factorio.ModsDir, "mod-list.json")
+	for _, mod := range enable {
+		if err := mods.SetModEnabled(modList, mod.Name, true); err != nil {
+			errs = append(errs, fmt.Errorf("failed to enable %s: %w", mod.Name, err))
+		}
+	}
+	if disableExtra {
+		for _, mod := range diff.Extra {
+			if err := mods.SetModEnabled(modList, mod.Name, false); err != nil {
+				errs = append(errs, fmt.Errorf("failed to disable %s: %w", mod.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeInstalledVersions removes every installed version of mod from the mods
+// directory. Downloaded copies are kept so they can be installed again.
+func removeInstalledVersions(cfg *config.FSMConfig, mod string) error {
+	installed, err := mods.InstalledVersions(cfg.Factorio.ModsDir)
+	if err != nil {
+		return err
+	}
+	for _, version := range installed[mod] {
+		if err := UninstallMod(cfg, mod, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -132,15 +132,29 @@ func DownloadAndExtractVersion(cfg *config.FSMConfig, branch string, version str
 }
 
 // SubscribeDownloadProgress registers a listener for progress updates during
-// download and unpack stages for a specific branch and version.
-func SubscribeDownloadProgress(branch string, version string) <-chan stageProgress {
+// download and unpack stages for a specific branch and version. The returned
+// function removes the listener.
+func SubscribeDownloadProgress(branch string, version string) (<-chan stageProgress, func()) {
 	downloadSubscribersMu.Lock()
 	defer downloadSubscribersMu.Unlock()
 
 	ch := make(chan stageProgress, 100)
 	key := fmt.Sprintf("%s-%s", branch, version)
 	downloadSubscribers[key] = append(downloadSubscribers[key], ch)
-	return ch
+	return ch, func() {
+		downloadSubscribersMu.Lock()
+		defer downloadSubscribersMu.Unlock()
+		subs := downloadSubscribers[key]
+		for i, sub := range subs {
+			if sub == ch {
+				downloadSubscribers[key] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(downloadSubscribers[key]) == 0 {
+			delete(downloadSubscribers, key)
+		}
+	}
 }
 
 // SendDownloadProgress emits progress updates to all subscribers for a given
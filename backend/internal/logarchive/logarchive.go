@@ -0,0 +1,309 @@
+// Package logarchive provides access to the console log files Factorio writes
+// into the logs directory, including listing, paging, searching and pruning
+// them according to a retention policy.
+package logarchive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timestampLayout is the prefix Factorio writes on every console log line.
+const timestampLayout = "2006-01-02 15:04:05"
+
+// fileNameLayout is the layout used by FSM when naming console log files.
+const fileNameLayout = "200601021504"
+
+// tailBytes is how much of the end of a file is read to find its last timestamp.
+const tailBytes = 64 * 1024
+
+// LogFile describes a console log file and the time range it covers.
+type LogFile struct {
+	End     *time.Time `json:"end,omitempty"`
+	ModTime time.Time  `json:"modTime"`
+	Name    string     `json:"name"`
+	Size    int64      `json:"size"`
+	Start   *time.Time `json:"start,omitempty"`
+}
+
+// Page is a window of lines read from a single log file.
+type Page struct {
+	HasMore bool     `json:"has_more"`
+	Lines   []string `json:"lines"`
+	Offset  int      `json:"offset"`
+}
+
+// Match is a single search hit.
+type Match struct {
+	File string     `json:"file"`
+	Line int        `json:"line"`
+	Text string     `json:"text"`
+	Time *time.Time `json:"time,omitempty"`
+}
+
+// Query describes a search across the archived log files.
+type Query struct {
+	From    time.Time // Ignore lines before this time when non-zero
+	Limit   int       // Maximum number of matches returned
+	Pattern string    // Substring or regular expression to look for
+	Regex   bool      // Treat Pattern as a regular expression
+	To      time.Time // Ignore lines after this time when non-zero
+}
+
+// Retention bounds the amount of log history kept on disk.
+type Retention struct {
+	MaxAge       time.Duration // Delete files older than this when non-zero
+	MaxTotalSize int64         // Delete the oldest files while the total exceeds this when non-zero
+}
+
+// List returns the log files in dir, newest first.
+func List(dir string) ([]LogFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]LogFile, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		file := LogFile{
+			ModTime: info.ModTime(),
+			Name:    entry.Name(),
+			Size:    info.Size(),
+		}
+		file.Start, file.End = timeRange(filepath.Join(dir, entry.Name()))
+		if file.Start == nil {
+			if t, err := time.ParseInLocation(fileNameLayout, strings.TrimSuffix(entry.Name(), ".log"), time.Local); err == nil {
+				file.Start = &t
+			}
+		}
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// Path resolves name to a log file inside dir, rejecting anything that is not
+// a plain .log file name.
+func Path(dir string, name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".log") {
+		return "", fmt.Errorf("invalid log file name %q", name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadPage returns up to limit lines of the named file starting at line offset.
+func ReadPage(dir string, name string, offset int, limit int) (*Page, error) {
+	path, err := Path(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	page := &Page{Lines: []string{}, Offset: offset}
+	scanner := newScanner(file)
+	for i := 0; scanner.Scan(); i++ {
+		if i < offset {
+			continue
+		}
+		if len(page.Lines) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Lines = append(page.Lines, scanner.Text())
+	}
+	return page, scanner.Err()
+}
+
+// Search looks for lines matching the query across every log file in dir,
+// returning matches from the newest files first.
+func Search(dir string, query Query) ([]Match, error) {
+	match, err := matcher(query)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []Match{}
+	for _, f := range files {
+		if !query.From.IsZero() && f.End != nil && f.End.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && f.Start != nil && f.Start.After(query.To) {
+			continue
+		}
+
+		found, err := searchFile(filepath.Join(dir, f.Name), f.Name, query, match, query.Limit-len(matches))
+		if err != nil {
+			log.Printf("Failed to search %s: %v\n", f.Name, err)
+			continue
+		}
+		matches = append(matches, found...)
+		if len(matches) >= query.Limit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// Prune deletes log files that fall outside the retention policy. The file
+// named keep, typically the log currently being written, is never removed.
+func Prune(dir string, retention Retention, keep string) error {
+	if retention.MaxAge <= 0 && retention.MaxTotalSize <= 0 {
+		return nil
+	}
+
+	files, err := List(dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	cutoff := time.Now().Add(-retention.MaxAge)
+	for _, f := range files {
+		if f.Name == keep {
+			total += f.Size
+			continue
+		}
+
+		expired := retention.MaxAge > 0 && f.ModTime.Before(cutoff)
+		oversized := retention.MaxTotalSize > 0 && total+f.Size > retention.MaxTotalSize
+		if expired || oversized {
+			path := filepath.Join(dir, f.Name)
+			if err := os.Remove(path); err != nil {
+				log.Printf("Failed to prune %s: %v\n", path, err)
+				continue
+			}
+			log.Printf("Pruned log file %s\n", path)
+			continue
+		}
+		total += f.Size
+	}
+	return nil
+}
+
+// matcher builds the line predicate for a query.
+func matcher(query Query) (func(string) bool, error) {
+	if query.Pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	if !query.Regex {
+		return func(line string) bool { return strings.Contains(line, query.Pattern) }, nil
+	}
+	re, err := regexp.Compile(query.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re.MatchString, nil
+}
+
+// searchFile returns up to limit matches from a single file.
+func searchFile(path string, name string, query Query, match func(string) bool, limit int) ([]Match, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var matches []Match
+	var current *time.Time
+	scanner := newScanner(file)
+	for i := 1; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if t, ok := lineTime(line); ok {
+			current = &t
+		}
+		if current != nil {
+			if !query.From.IsZero() && current.Before(query.From) {
+				continue
+			}
+			if !query.To.IsZero() && current.After(query.To) {
+				break
+			}
+		}
+		if !match(line) {
+			continue
+		}
+		matches = append(matches, Match{File: name, Line: i, Text: line, Time: current})
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches, scanner.Err()
+}
+
+// timeRange returns the first and last timestamps found in a log file.
+func timeRange(path string) (*time.Time, *time.Time) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	var start, end *time.Time
+	scanner := newScanner(file)
+	for scanner.Scan() {
+		if t, ok := lineTime(scanner.Text()); ok {
+			start = &t
+			break
+		}
+	}
+
+	if info, err := file.Stat(); err == nil && info.Size() > tailBytes {
+		file.Seek(info.Size()-tailBytes, io.SeekStart)
+	} else {
+		file.Seek(0, io.SeekStart)
+	}
+	scanner = newScanner(file)
+	for scanner.Scan() {
+		if t, ok := lineTime(scanner.Text()); ok {
+			end = &t
+		}
+	}
+	return start, end
+}
+
+// lineTime parses the timestamp prefix of a console log line.
+func lineTime(line string) (time.Time, bool) {
+	if len(line) < len(timestampLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(timestampLayout, line[:len(timestampLayout)], time.Local)
+	return t, err == nil
+}
+
+// newScanner returns a line scanner that tolerates long log lines.
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
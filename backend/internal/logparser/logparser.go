@@ -0,0 +1,163 @@
+// Package logparser recognises the line formats written by the Factorio headless
+// server and turns them into typed events, such as players joining or leaving,
+// chat messages, moderation actions, saves and errors.
+package logparser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EventType identifies the kind of event parsed from a log line.
+type EventType string
+
+const (
+	EventBan          EventType = "ban"
+	EventChat         EventType = "chat"
+	EventCommand      EventType = "command"
+	EventDemote       EventType = "demote"
+	EventDesync       EventType = "desync"
+	EventJoin         EventType = "join"
+	EventKick         EventType = "kick"
+	EventLeave        EventType = "leave"
+	EventModError     EventType = "mod_error"
+	EventPromote      EventType = "promote"
+	EventSaveFinished EventType = "save_finished"
+	EventSaveStarted  EventType = "save_started"
+	EventStateChange  EventType = "state_change"
+	EventUnban        EventType = "unban"
+)
+
+// Event is a structured representation of a single Factorio log line.
+type Event struct {
+	Actor   string            `json:"actor,omitempty"`   // Admin responsible for a kick, ban, promotion, etc.
+	Details map[string]string `json:"details,omitempty"` // Event specific values, e.g. reason or save name
+	Message string            `json:"message,omitempty"` // Chat text, command or error message
+	Player  string            `json:"player,omitempty"`  // Player the event is about
+	Raw     string            `json:"raw"`               // The original log line
+	Seq     uint64            `json:"seq,omitempty"`     // Sequence number of the originating log line
+	Time    time.Time         `json:"time"`              // Time of the event
+	Type    EventType         `json:"type"`              // Kind of event
+}
+
+// consoleTimeLayout is the timestamp prefix of console (bracketed) log lines.
+const consoleTimeLayout = "2006-01-02 15:04:05"
+
+var (
+	consoleLine  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) \[([A-Z]+)\] (.*)$`)
+	timedLine    = regexp.MustCompile(`^\s*\d+\.\d+ (\w+) (\S+): (.*)$`)
+	joinLine     = regexp.MustCompile(`^(.+?) joined the game$`)
+	leaveLine    = regexp.MustCompile(`^(.+?) left the game$`)
+	chatLine     = regexp.MustCompile(`^(.+?)(?: \[[^\]]*\])?: (.*)$`)
+	commandLine  = regexp.MustCompile(`^(.+?) \(command\): (.*)$`)
+	kickLine     = regexp.MustCompile(`^(.+?) was kicked by (.+?)\. Reason: (.*?)\.?$`)
+	banLine      = regexp.MustCompile(`^(.+?)(?: \(not on map\))? was banned by (.+?)\. Reason: (.*?)\.?$`)
+	unbanLine    = regexp.MustCompile(`^(.+?) was unbanned by (.+?)\.?$`)
+	promoteLine  = regexp.MustCompile(`^(.+?) was promoted to admin by (.+?)\.?$`)
+	demoteLine   = regexp.MustCompile(`^(.+?) was demoted from admin by (.+?)\.?$`)
+	savingAsLine = regexp.MustCompile(`^Saving (?:game as|to) (\S+)`)
+	stateLine    = regexp.MustCompile(`changing state from\((\w+)\) to\((\w+)\)`)
+)
+
+// Parse converts a raw log line into an Event. The received time is used for
+// lines that do not carry a wall clock timestamp. The boolean result is false
+// when the line is not one of the recognised formats.
+func Parse(line string, received time.Time) (Event, bool) {
+	if m := consoleLine.FindStringSubmatch(line); m != nil {
+		ts, err := time.ParseInLocation(consoleTimeLayout, m[1], time.Local)
+		if err != nil {
+			ts = received
+		}
+		return parseConsole(m[2], m[3], line, ts)
+	}
+
+	if m := timedLine.FindStringSubmatch(line); m != nil {
+		return parseTimed(m[1], m[2], m[3], line, received)
+	}
+
+	return Event{}, false
+}
+
+// parseConsole handles the bracketed lines written to the console log.
+func parseConsole(tag, body, raw string, ts time.Time) (Event, bool) {
+	event := Event{Raw: raw, Time: ts}
+
+	switch tag {
+	case "JOIN":
+		if m := joinLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player = EventJoin, m[1]
+			return event, true
+		}
+	case "LEAVE":
+		if m := leaveLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player = EventLeave, m[1]
+			return event, true
+		}
+	case "CHAT":
+		if m := chatLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Message = EventChat, m[1], m[2]
+			return event, true
+		}
+	case "COMMAND":
+		if m := commandLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Message = EventCommand, m[1], m[2]
+			return event, true
+		}
+	case "KICK":
+		if m := kickLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Actor = EventKick, m[1], m[2]
+			event.Details = map[string]string{"reason": m[3]}
+			return event, true
+		}
+	case "BAN":
+		if m := banLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Actor = EventBan, m[1], m[2]
+			event.Details = map[string]string{"reason": m[3]}
+			return event, true
+		}
+	case "UNBANNED":
+		if m := unbanLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Actor = EventUnban, m[1], m[2]
+			return event, true
+		}
+	case "PROMOTE":
+		if m := promoteLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Actor = EventPromote, m[1], m[2]
+			return event, true
+		}
+	case "DEMOTE":
+		if m := demoteLine.FindStringSubmatch(body); m != nil {
+			event.Type, event.Player, event.Actor = EventDemote, m[1], m[2]
+			return event, true
+		}
+	}
+
+	return Event{}, false
+}
+
+// parseTimed handles the "<uptime> <Level> <source>: <message>" lines written
+// to stdout by the server.
+func parseTimed(level, source, message, raw string, ts time.Time) (Event, bool) {
+	event := Event{Raw: raw, Time: ts, Message: message}
+
+	switch {
+	case stateLine.MatchString(message):
+		m := stateLine.FindStringSubmatch(message)
+		event.Type = EventStateChange
+		event.Details = map[string]string{"from": m[1], "to": m[2]}
+	case savingAsLine.MatchString(message):
+		event.Type = EventSaveStarted
+		event.Details = map[string]string{"save": savingAsLine.FindStringSubmatch(message)[1]}
+	case strings.HasPrefix(message, "Saving finished"):
+		event.Type = EventSaveFinished
+	case strings.Contains(strings.ToLower(message), "desync"):
+		event.Type = EventDesync
+	case level == "Error" && (strings.HasPrefix(source, "Mod") || strings.Contains(message, "Failed to load mods")):
+		event.Type = EventModError
+	default:
+		return Event{}, false
+	}
+
+	return event, true
+}
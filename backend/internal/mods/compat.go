@@ -0,0 +1,188 @@
+package mods
+
+// Compatibility of the mods a save was made with against the mods installed in
+// the mods directory and their enabled state in mod-list.json.
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// builtinMods ship with the game and are never installed into the mods directory.
+var builtinMods = map[string]bool{
+	"base":           true,
+	"core":           true,
+	"elevated-rails": true,
+	"quality":        true,
+	"space-age":      true,
+}
+
+// IsBuiltin reports whether name is a mod shipped with the game.
+func IsBuiltin(name string) bool {
+	return builtinMods[name]
+}
+
+// Mod identifies a mod version.
+type Mod struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Mismatch is a mod that is installed, but not in the version the save requires.
+type Mismatch struct {
+	Installed []string `json:"installed"`
+	Name      string   `json:"name"`
+	Required  string   `json:"required"`
+}
+
+// Diff lists the differences between the mods of a save and the installed mods.
+type Diff struct {
+	Disabled        []Mod      `json:"disabled"`         // Required and installed in the right version, but disabled
+	Extra           []Mod      `json:"extra"`            // Enabled but not used by the save
+	Missing         []Mod      `json:"missing"`          // Required but not installed in any version
+	VersionMismatch []Mismatch `json:"version_mismatch"` // Required but installed only in other versions
+}
+
+// Compatible reports whether the save can load with exactly its own mods.
+func (d *Diff) Compatible() bool {
+	return len(d.Disabled) == 0 && len(d.Extra) == 0 && len(d.Missing) == 0 && len(d.VersionMismatch) == 0
+}
+
+// Blocking reports whether loading the save would drop content: a mod it uses
+// is missing or disabled. Version differences and extra mods are migrated by
+// the game when the save loads.
+func (d *Diff) Blocking() bool {
+	return len(d.Disabled) > 0 || len(d.Missing) > 0
+}
+
+// MarshalJSON adds the compatible and blocking flags to the encoded diff.
+func (d *Diff) MarshalJSON() ([]byte, error) {
+	type plain Diff
+	return json.Marshal(struct {
+		*plain
+		Blocking   bool `json:"blocking"`
+		Compatible bool `json:"compatible"`
+	}{(*plain)(d), d.Blocking(), d.Compatible()})
+}
+
+// Compare diffs the mods a save requires against the mods installed in modsDir
+// and enabled in its mod-list.json. Built-in mods are only checked for their
+// enabled state, since their version follows the game. Installed mods missing
+// from mod-list.json are treated as enabled, as the game does.
+func Compare(modsDir string, required []Mod) (*Diff, error) {
+	installed, err := InstalledVersions(modsDir)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := enabledStates(filepath.Join(modsDir, "mod-list.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &Diff{Disabled: []Mod{}, Extra: []Mod{}, Missing: []Mod{}, VersionMismatch: []Mismatch{}}
+	requiredNames := map[string]bool{}
+	for _, mod := range required {
+		requiredNames[mod.Name] = true
+		on, listed := enabled[mod.Name]
+		isEnabled := on || !listed
+
+		if IsBuiltin(mod.Name) {
+			if !isEnabled {
+				diff.Disabled = append(diff.Disabled, mod)
+			}
+			continue
+		}
+
+		versions := installed[mod.Name]
+		switch {
+		case len(versions) == 0:
+			diff.Missing = append(diff.Missing, mod)
+		case !contains(versions, mod.Version):
+			diff.VersionMismatch = append(diff.VersionMismatch, Mismatch{Installed: versions, Name: mod.Name, Required: mod.Version})
+		case !isEnabled:
+			diff.Disabled = append(diff.Disabled, mod)
+		}
+	}
+
+	for name, on := range enabled {
+		if !on || requiredNames[name] {
+			continue
+		}
+		if !IsBuiltin(name) && len(installed[name]) == 0 {
+			continue
+		}
+		diff.Extra = append(diff.Extra, Mod{Name: name, Version: strings.Join(installed[name], ",")})
+	}
+	for name, versions := range installed {
+		if _, listed := enabled[name]; !listed && !requiredNames[name] {
+			diff.Extra = append(diff.Extra, Mod{Name: name, Version: strings.Join(versions, ",")})
+		}
+	}
+	sort.Slice(diff.Extra, func(i, j int) bool { return diff.Extra[i].Name < diff.Extra[j].Name })
+	return diff, nil
+}
+
+// InstalledVersions returns the versions of each mod installed in modsDir as
+// <name>_<version>.zip files or unpacked <name>_<version> directories.
+func InstalledVersions(modsDir string) (map[string][]string, error) {
+	entries, err := os.ReadDir(modsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string][]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() {
+			var ok bool
+			if name, ok = strings.CutSuffix(name, ".zip"); !ok {
+				continue
+			}
+		} else if !entry.IsDir() {
+			continue
+		}
+		sep := strings.LastIndex(name, "_")
+		if sep == -1 {
+			continue
+		}
+		versions[name[:sep]] = append(versions[name[:sep]], name[sep+1:])
+	}
+	for _, list := range versions {
+		sort.Strings(list)
+	}
+	return versions, nil
+}
+
+// enabledStates reads the enabled state of each mod in mod-list.json. A missing
+// file is treated as an empty list.
+func enabledStates(modListPath string) (map[string]bool, error) {
+	data, err := os.ReadFile(modListPath)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var modList ModList
+	if err := json.Unmarshal(data, &modList); err != nil {
+		return nil, err
+	}
+	states := make(map[string]bool, len(modList.Mods))
+	for _, mod := range modList.Mods {
+		states[mod.Name] = mod.Enabled
+	}
+	return states, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
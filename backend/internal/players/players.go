@@ -0,0 +1,284 @@
+// Package players keeps track of which players are connected to the Factorio
+// server and persists a per-player session history to a local JSON store.
+package players
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSessionsPerPlayer bounds the session history stored for each player.
+const maxSessionsPerPlayer = 200
+
+// Session is a single connection of a player to the server.
+type Session struct {
+	End   *time.Time `json:"end,omitempty"`
+	Start time.Time  `json:"start"`
+}
+
+// Player holds the aggregated connection history of a single player.
+type Player struct {
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Name         string    `json:"name"`
+	Online       bool      `json:"online"`
+	Playtime     int64     `json:"playtime_seconds"`
+	SessionCount int       `json:"session_count"`
+	Sessions     []Session `json:"sessions,omitempty"`
+}
+
+// Tracker maintains the online player list and session history.
+type Tracker struct {
+	mu      sync.Mutex
+	path    string
+	players map[string]*Player
+}
+
+// NewTracker returns an empty tracker that persists to path.
+func NewTracker(path string) *Tracker {
+	return &Tracker{path: path, players: map[string]*Player{}}
+}
+
+// Open loads the player store at path, creating an empty one if it does not exist.
+// Sessions left open by an FSM that exited without ending them are closed at the
+// time their player was last seen, so the downtime is not counted as playtime.
+func Open(path string) (*Tracker, error) {
+	t := NewTracker(path)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var players []*Player
+	if err := json.Unmarshal(data, &players); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	stale := false
+	for _, p := range players {
+		t.players[p.Name] = p
+		if p.Online {
+			stale = t.leave(p.Name, p.LastSeen) || stale
+		}
+	}
+	if stale {
+		if err := t.save(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Join records that a player connected at the given time.
+func (t *Tracker) Join(name string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.join(name, at) {
+		return t.save()
+	}
+	return nil
+}
+
+// Leave records that a player disconnected at the given time.
+func (t *Tracker) Leave(name string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.leave(name, at) {
+		return t.save()
+	}
+	return nil
+}
+
+// Sync reconciles the tracked state with an authoritative list of online players,
+// opening sessions for players not yet tracked and closing those no longer present.
+// The last seen time of players still online is saved as well, so Open can close
+// their sessions close to when they ended if FSM exits without ending them.
+func (t *Tracker) Sync(online []string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	present := map[string]bool{}
+	changed := false
+	for _, name := range online {
+		present[name] = true
+		if !t.join(name, at) {
+			t.players[name].LastSeen = at
+		}
+		changed = true
+	}
+	for name, p := range t.players {
+		if p.Online && !present[name] {
+			changed = t.leave(name, at) || changed
+		}
+	}
+
+	if changed {
+		return t.save()
+	}
+	return nil
+}
+
+// EndAll closes the sessions of every online player, e.g. when the server stops.
+func (t *Tracker) EndAll(at time.Time) error {
+	return t.Sync(nil, at)
+}
+
+// Get returns a copy of a single player's record including their sessions.
+func (t *Tracker) Get(name string) (Player, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.players[name]
+	if !ok {
+		return Player{}, false
+	}
+	return t.snapshot(p, true), true
+}
+
+// List returns a summary of every known player, sorted by name.
+func (t *Tracker) List() []Player {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	players := make([]Player, 0, len(t.players))
+	for _, p := range t.players {
+		players = append(players, t.snapshot(p, false))
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return strings.ToLower(players[i].Name) < strings.ToLower(players[j].Name)
+	})
+	return players
+}
+
+// Online returns the names of the players currently connected.
+func (t *Tracker) Online() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := []string{}
+	for name, p := range t.players {
+		if p.Online {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OnlineAt returns the names of the players whose recorded sessions cover the given time.
+func (t *Tracker) OnlineAt(at time.Time) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := []string{}
+	for name, p := range t.players {
+		for _, session := range p.Sessions {
+			if session.Start.After(at) {
+				continue
+			}
+			if session.End == nil || !session.End.Before(at) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// join opens a session for name unless one is already open. The caller must hold t.mu.
+func (t *Tracker) join(name string, at time.Time) bool {
+	p, ok := t.players[name]
+	if !ok {
+		p = &Player{Name: name, FirstSeen: at}
+		t.players[name] = p
+	}
+	if p.Online {
+		return false
+	}
+
+	p.Online = true
+	p.LastSeen = at
+	p.SessionCount++
+	p.Sessions = append(p.Sessions, Session{Start: at})
+	if len(p.Sessions) > maxSessionsPerPlayer {
+		p.Sessions = p.Sessions[len(p.Sessions)-maxSessionsPerPlayer:]
+	}
+	return true
+}
+
+// leave closes the open session for name, if any. The caller must hold t.mu.
+func (t *Tracker) leave(name string, at time.Time) bool {
+	p, ok := t.players[name]
+	if !ok || !p.Online {
+		return false
+	}
+
+	p.Online = false
+	p.LastSeen = at
+	if n := len(p.Sessions); n > 0 && p.Sessions[n-1].End == nil {
+		end := at
+		p.Sessions[n-1].End = &end
+		p.Playtime += int64(end.Sub(p.Sessions[n-1].Start).Seconds())
+	}
+	return true
+}
+
+// snapshot copies a player record, adding the time of any open session to the
+// playtime. The caller must hold t.mu.
+func (t *Tracker) snapshot(p *Player, withSessions bool) Player {
+	out := *p
+	out.Sessions = nil
+	if withSessions {
+		out.Sessions = append([]Session(nil), p.Sessions...)
+	}
+	if n := len(p.Sessions); p.Online && n > 0 {
+		out.Playtime += int64(time.Since(p.Sessions[n-1].Start).Seconds())
+	}
+	return out
+}
+
+// save writes the store to disk atomically. The caller must hold t.mu.
+func (t *Tracker) save() error {
+	players := make([]*Player, 0, len(t.players))
+	for _, p := range t.players {
+		players = append(players, p)
+	}
+	data, err := json.MarshalIndent(players, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// ParseOnline extracts player names from the output of the /players online command.
+func ParseOnline(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Online players") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(line, " (online)"))
+	}
+	return names
+}
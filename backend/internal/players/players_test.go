@@ -0,0 +1,108 @@
+package players
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOpenClosesStaleSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.json")
+	tracker, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	if err := tracker.Sync([]string{"alice", "bob"}, start); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	if err := tracker.Leave("bob", start.Add(time.Minute)); err != nil {
+		t.Fatalf("Leave() = %v", err)
+	}
+	// FSM exits without ending alice's session after last seeing her at 12:10.
+	lastSeen := start.Add(10 * time.Minute)
+	if err := tracker.Sync([]string{"alice"}, lastSeen); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+
+	for _, reopen := range []string{"first", "second"} {
+		tracker, err = Open(path)
+		if err != nil {
+			t.Fatalf("%s Open() = %v", reopen, err)
+		}
+		if online := tracker.Online(); len(online) != 0 {
+			t.Errorf("%s Open(): online = %v, want none", reopen, online)
+		}
+		alice, _ := tracker.Get("alice")
+		if n := len(alice.Sessions); n != 1 || alice.Sessions[0].End == nil || !alice.Sessions[0].End.Equal(lastSeen) {
+			t.Fatalf("%s Open(): sessions = %+v, want one ending at %s", reopen, alice.Sessions, lastSeen)
+		}
+		if alice.Playtime != 600 {
+			t.Errorf("%s Open(): playtime = %d, want 600", reopen, alice.Playtime)
+		}
+		bob, _ := tracker.Get("bob")
+		if bob.Playtime != 60 || !bob.LastSeen.Equal(start.Add(time.Minute)) {
+			t.Errorf("%s Open(): bob = %+v, want unchanged", reopen, bob)
+		}
+	}
+}
+
+func TestTrackerSync(t *testing.T) {
+	tracker := NewTracker(filepath.Join(t.TempDir(), "players.json"))
+	start := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		online []string
+		at     time.Duration
+	}{
+		{[]string{"alice"}, 0},
+		{[]string{"alice", "bob"}, time.Minute},
+		{[]string{"bob"}, 2 * time.Minute},
+		{nil, 3 * time.Minute},
+		{[]string{"alice"}, 4 * time.Minute},
+	}
+	for _, step := range steps {
+		if err := tracker.Sync(step.online, start.Add(step.at)); err != nil {
+			t.Fatalf("Sync() = %v", err)
+		}
+	}
+
+	if got, want := tracker.Online(), []string{"alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Online() = %v, want %v", got, want)
+	}
+	alice, _ := tracker.Get("alice")
+	if alice.SessionCount != 2 || len(alice.Sessions) != 2 {
+		t.Errorf("alice has %d sessions, want 2", alice.SessionCount)
+	}
+	bob, _ := tracker.Get("bob")
+	if bob.Playtime != 120 || bob.Online {
+		t.Errorf("bob = %+v, want offline with 120s playtime", bob)
+	}
+
+	tests := []struct {
+		at   time.Duration
+		want []string
+	}{
+		{-time.Second, []string{}},
+		{30 * time.Second, []string{"alice"}},
+		{time.Minute, []string{"alice", "bob"}},
+		{150 * time.Second, []string{"bob"}},
+		{210 * time.Second, []string{}},
+		{time.Hour, []string{"alice"}},
+	}
+	for _, tt := range tests {
+		if got := tracker.OnlineAt(start.Add(tt.at)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OnlineAt(+%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestParseOnline(t *testing.T) {
+	output := "Online players (2):\n  alice (online)\n  bob smith (online)\n\n"
+	if got, want := ParseOnline(output), []string{"alice", "bob smith"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOnline() = %v, want %v", got, want)
+	}
+	if got := ParseOnline("Online players (0):\n"); len(got) != 0 {
+		t.Errorf("ParseOnline() = %v, want none", got)
+	}
+}
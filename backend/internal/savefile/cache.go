@@ -0,0 +1,52 @@
+package savefile
+
+// A cache of decoded save headers, so listing saves does not reopen and inflate
+// every save on each request.
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the cache; it is emptied when full.
+const maxCacheEntries = 1000
+
+// Cache remembers the header of each save by path, size and modification time.
+// The zero value is ready to use.
+type Cache struct {
+	entries map[string]cacheEntry
+	mu      sync.Mutex
+}
+
+type cacheEntry struct {
+	err     error
+	info    *Info
+	modTime time.Time
+	size    int64
+}
+
+// Read returns the header of the save at savePath, decoding it only when the
+// file changed since it was last read. Decoding errors are cached as well.
+func (c *Cache) Read(savePath string) (*Info, error) {
+	stat, err := os.Stat(savePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[savePath]
+	c.mu.Unlock()
+	if ok && entry.size == stat.Size() && entry.modTime.Equal(stat.ModTime()) {
+		return entry.info, entry.err
+	}
+
+	info, err := Read(savePath)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= maxCacheEntries {
+		c.entries = map[string]cacheEntry{}
+	}
+	c.entries[savePath] = cacheEntry{err: err, info: info, modTime: stat.ModTime(), size: stat.Size()}
+	return info, err
+}
@@ -0,0 +1,56 @@
+package savefile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "world.zip")
+	writeSave(t, path, map[string][]byte{"world/level.dat0": validHeader(true)})
+
+	var cache Cache
+	first, err := cache.Read(path)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	second, err := cache.Read(path)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if first != second {
+		t.Error("unchanged save was decoded again")
+	}
+
+	// Same size, newer modification time.
+	writeSave(t, path, map[string][]byte{"world/level.dat0": validHeader(true)})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	third, err := cache.Read(path)
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if third == second {
+		t.Error("modified save was not decoded again")
+	}
+
+	// Replaced by a save without map data: the error is returned and cached.
+	writeSave(t, path, map[string][]byte{"world/control.lua": nil})
+	if _, err := cache.Read(path); err != ErrNoHeader {
+		t.Errorf("Read() = %v, want ErrNoHeader", err)
+	}
+	if _, err := cache.Read(path); err != ErrNoHeader {
+		t.Errorf("cached Read() = %v, want ErrNoHeader", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Read(path); !os.IsNotExist(err) {
+		t.Errorf("Read() of a removed save = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,309 @@
+// Package savefile reads the header Factorio writes at the start of the map data
+// inside a save zip: the game version the save was made with, the scenario it
+// was started from and the mods, with versions, that were active.
+//
+// Only the header is decoded. The tick count, map seed and playtime are stored
+// further into the map data, after the serialized startup settings, and are not
+// extracted. Saves made before Factorio 0.17 are not supported.
+package savefile
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxHeaderSize = 1 << 20 // Bytes of map data read to decode the header
+	maxModCount   = 10000   // Mod counts above this indicate a misparsed header
+	maxStringSize = 4096    // String lengths above this indicate a misparsed header
+)
+
+// InitialMapFile holds the map as it was created. Its header records the version
+// and mods the map was created with, which may differ from the current ones.
+const InitialMapFile = "level-init.dat"
+
+// headerFiles are the zip entries holding the map data, in order of preference:
+// level.dat0 is the first chunk of the current map since 0.17 and level.dat the
+// current map of older versions. The initial map is only used without either.
+var headerFiles = []string{"level.dat0", "level.dat", InitialMapFile}
+
+// ErrNoHeader is returned when a zip does not contain Factorio map data.
+var ErrNoHeader = errors.New("no map data found in save")
+
+// Version is a Factorio game version.
+type Version struct {
+	Build uint16 `json:"build"`
+	Major uint16 `json:"major"`
+	Minor uint16 `json:"minor"`
+	Patch uint16 `json:"patch"`
+}
+
+// String returns the version as major.minor.patch.
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 when v is older than, equal to or newer than other,
+// ignoring the build number.
+func (v Version) Compare(other Version) int {
+	for _, d := range [][2]uint16{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if d[0] < d[1] {
+			return -1
+		}
+		if d[0] > d[1] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// ParseVersion parses a version such as "1.1.110".
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	var numbers [3]uint16
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 16)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		numbers[i] = uint16(n)
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// Mod is a mod recorded in a save.
+type Mod struct {
+	CRC     uint32 `json:"crc"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Info is the decoded header of a save.
+type Info struct {
+	BaseMod    string  `json:"base_mod"`
+	Campaign   string  `json:"campaign,omitempty"`
+	Level      string  `json:"level"`
+	LoadedFrom string  `json:"loaded_from"`
+	Mods       []Mod   `json:"mods"`
+	Source     string  `json:"source"` // Zip entry the header was read from
+	Version    Version `json:"version"`
+}
+
+// FromInitialMap reports whether the header was read from the map as it was
+// created, because the save holds no current map data. The version and mods may
+// then be out of date.
+func (i *Info) FromInitialMap() bool {
+	return i.Source == InitialMapFile
+}
+
+// Read decodes the header of the save zip at savePath.
+func Read(savePath string) (*Info, error) {
+	archive, err := zip.OpenReader(savePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	for _, name := range headerFiles {
+		for _, file := range archive.File {
+			if path.Base(file.Name) != name {
+				continue
+			}
+			data, err := readEntry(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+			}
+			info, err := parseHeader(data)
+			if err != nil {
+				return nil, err
+			}
+			info.Source = name
+			return info, nil
+		}
+	}
+	return nil, ErrNoHeader
+}
+
+// readEntry returns the start of a zip entry, inflating it when Factorio has
+// zlib-compressed the map data.
+func readEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	r := bufio.NewReader(rc)
+	magic, err := r.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	var src io.Reader = r
+	if isZlib(magic) {
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, maxHeaderSize))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseHeader decodes the header. Newer versions write one extra byte after the
+// game version; both layouts are tried and the first giving a plausible mod
+// list is used.
+func parseHeader(data []byte) (*Info, error) {
+	var firstErr error
+	for _, extraByte := range []bool{true, false} {
+		info, err := parseLayout(data, extraByte)
+		if err == nil {
+			return info, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, fmt.Errorf("unrecognised save header: %w", firstErr)
+}
+
+func parseLayout(data []byte, extraByte bool) (*Info, error) {
+	r := &reader{r: bytes.NewReader(data)}
+	info := &Info{}
+
+	info.Version = Version{Major: r.u16(), Minor: r.u16(), Patch: r.u16(), Build: r.u16()}
+	if info.Version.Compare(Version{Minor: 17}) < 0 {
+		return nil, fmt.Errorf("unsupported save version %s", info.Version)
+	}
+	if extraByte {
+		r.u8()
+	}
+
+	info.Campaign = r.string()
+	info.Level = r.string()
+	info.BaseMod = r.string()
+	r.u8()     // difficulty
+	r.bool()   // finished
+	r.bool()   // player won
+	r.string() // next level
+	r.bool()   // can continue
+	r.bool()   // finished but continuing
+	r.bool()   // saving replay
+	r.bool()   // allow non-admin debug options
+	loadedFrom := Version{Major: uint16(r.u8()), Minor: uint16(r.u8()), Patch: uint16(r.u8())}
+	loadedFrom.Build = r.u16()
+	info.LoadedFrom = loadedFrom.String()
+	r.u8() // allowed commands
+
+	count := r.optimizedU32()
+	if r.err == nil && count > maxModCount {
+		return nil, fmt.Errorf("implausible mod count %d", count)
+	}
+	for range count {
+		mod := Mod{Name: r.string()}
+		mod.Version = fmt.Sprintf("%d.%d.%d", r.optimizedU16(), r.optimizedU16(), r.optimizedU16())
+		mod.CRC = r.u32()
+		if r.err != nil {
+			break
+		}
+		info.Mods = append(info.Mods, mod)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if !hasMod(info.Mods, "base") {
+		return nil, errors.New("mod list does not contain base")
+	}
+	return info, nil
+}
+
+func hasMod(mods []Mod, name string) bool {
+	for _, mod := range mods {
+		if mod.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reader decodes Factorio's little-endian serialization, remembering the first
+// error so fields can be read without checking each one.
+type reader struct {
+	err error
+	r   *bytes.Reader
+}
+
+func (r *reader) read(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		r.err = fmt.Errorf("truncated header: %w", err)
+	}
+	return buf
+}
+
+func (r *reader) u8() uint8   { return r.read(1)[0] }
+func (r *reader) u16() uint16 { return binary.LittleEndian.Uint16(r.read(2)) }
+func (r *reader) u32() uint32 { return binary.LittleEndian.Uint32(r.read(4)) }
+
+func (r *reader) bool() bool {
+	b := r.u8()
+	if b > 1 && r.err == nil {
+		r.err = fmt.Errorf("invalid boolean %d", b)
+	}
+	return b == 1
+}
+
+// optimizedU16 reads a number stored in one byte, or 0xFF followed by a u16.
+func (r *reader) optimizedU16() uint16 {
+	if b := r.u8(); b != 0xFF {
+		return uint16(b)
+	}
+	return r.u16()
+}
+
+// optimizedU32 reads a number stored in one byte, or 0xFF followed by a u32.
+func (r *reader) optimizedU32() uint32 {
+	if b := r.u8(); b != 0xFF {
+		return uint32(b)
+	}
+	return r.u32()
+}
+
+// string reads a length-prefixed UTF-8 string.
+func (r *reader) string() string {
+	n := r.optimizedU32()
+	if r.err != nil {
+		return ""
+	}
+	if n > maxStringSize {
+		r.err = fmt.Errorf("implausible string length %d", n)
+		return ""
+	}
+	return string(r.read(int(n)))
+}
+
+// isZlib reports whether magic is a valid zlib stream header.
+func isZlib(magic []byte) bool {
+	return magic[0]&0x0F == 8 && (uint16(magic[0])<<8|uint16(magic[1]))%31 == 0
+}
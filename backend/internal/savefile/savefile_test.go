@@ -0,0 +1,276 @@
+package savefile
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// header builds map data in Factorio's serialization for the parser tests.
+type header struct {
+	bytes.Buffer
+}
+
+func (h *header) u8(v uint8) *header   { h.WriteByte(v); return h }
+func (h *header) u16(v uint16) *header { h.Write(binary.LittleEndian.AppendUint16(nil, v)); return h }
+func (h *header) u32(v uint32) *header { h.Write(binary.LittleEndian.AppendUint32(nil, v)); return h }
+
+func (h *header) optimized(v uint32) *header {
+	if v < 0xFF {
+		return h.u8(uint8(v))
+	}
+	return h.u8(0xFF).u32(v)
+}
+
+func (h *header) string(s string) *header {
+	h.optimized(uint32(len(s)))
+	h.WriteString(s)
+	return h
+}
+
+// validHeader returns the header of a 2.0.28 save with the base mod and one
+// other mod, optionally with the byte newer versions write after the version.
+func validHeader(extraByte bool) []byte {
+	h := &header{}
+	h.u16(2).u16(0).u16(28).u16(7994)
+	if extraByte {
+		h.u8(0)
+	}
+	h.string("").string("freeplay").string("base")
+	h.u8(0)       // difficulty
+	h.u8(0).u8(0) // finished, player won
+	h.string("")  // next level
+	h.u8(0).u8(0) // can continue, finished but continuing
+	h.u8(0).u8(0) // saving replay, allow non-admin debug options
+	h.u8(2).u8(0).u8(28).u16(7994)
+	h.u8(1) // allowed commands
+	h.optimized(2)
+	h.string("base").u8(2).u8(0).u8(28).u32(0x12345678)
+	h.string("space-age").u8(2).u8(0).u8(0xFF).u16(300).u32(0x9abcdef0)
+	return h.Bytes()
+}
+
+// headerUpToMods writes a 1.1.110 header up to, but not including, the mod list.
+func headerUpToMods(h *header) *header {
+	h.u16(1).u16(1).u16(110).u16(0).u8(0)
+	h.string("").string("freeplay").string("base")
+	h.u8(0).u8(0).u8(0).string("").u8(0).u8(0).u8(0).u8(0)
+	h.u8(1).u8(1).u8(110).u16(0).u8(1)
+	return h
+}
+
+func TestParseHeader(t *testing.T) {
+	want := &Info{
+		BaseMod:    "base",
+		Level:      "freeplay",
+		LoadedFrom: "2.0.28",
+		Mods: []Mod{
+			{CRC: 0x12345678, Name: "base", Version: "2.0.28"},
+			{CRC: 0x9abcdef0, Name: "space-age", Version: "2.0.300"},
+		},
+		Version: Version{Build: 7994, Major: 2, Minor: 0, Patch: 28},
+	}
+	for _, extraByte := range []bool{true, false} {
+		got, err := parseHeader(validHeader(extraByte))
+		if err != nil {
+			t.Fatalf("extra byte %v: parseHeader() = %v", extraByte, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("extra byte %v: parseHeader() = %+v, want %+v", extraByte, got, want)
+		}
+	}
+}
+
+func TestParseHeaderMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "old version", data: (&header{}).u16(0).u16(16).u16(51).u16(0).Bytes()},
+		{name: "huge string length", data: (&header{}).u16(1).u16(1).u16(110).u16(0).u8(0).u8(0xFF).u32(0xFFFFFFFF).Bytes()},
+		{name: "string beyond data", data: (&header{}).u16(1).u16(1).u16(110).u16(0).u8(0).u8(200).Bytes()},
+		{name: "invalid boolean", data: (&header{}).u16(1).u16(1).u16(110).u16(0).u8(0).string("").string("freeplay").string("base").u8(0).u8(7).Bytes()},
+		{name: "huge mod count", data: headerUpToMods(&header{}).u8(0xFF).u32(0xFFFFFFFF).Bytes()},
+		{name: "mod count beyond data", data: headerUpToMods(&header{}).optimized(maxModCount).string("base").Bytes()},
+		{name: "no mods", data: headerUpToMods(&header{}).optimized(0).Bytes()},
+		{name: "no base mod", data: headerUpToMods(&header{}).optimized(1).string("other").u8(1).u8(0).u8(0).u32(0).Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if info, err := parseHeader(tt.data); err == nil {
+				t.Errorf("parseHeader() = %+v, want an error", info)
+			}
+		})
+	}
+}
+
+func TestParseHeaderTruncated(t *testing.T) {
+	for _, extraByte := range []bool{true, false} {
+		data := validHeader(extraByte)
+		for n := range len(data) {
+			if info, err := parseHeader(data[:n]); err == nil {
+				t.Errorf("extra byte %v: parseHeader() of %d of %d bytes = %+v, want an error", extraByte, n, len(data), info)
+			}
+		}
+	}
+}
+
+func TestParseHeaderRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	valid := validHeader(true)
+	for range 10000 {
+		data := make([]byte, rng.Intn(256))
+		rng.Read(data)
+		parseHeader(data)
+
+		// Corrupt a few bytes of a valid header, keeping the version so the
+		// parser gets past the version check.
+		data = bytes.Clone(valid)
+		for range 1 + rng.Intn(4) {
+			data[8+rng.Intn(len(data)-8)] = byte(rng.Intn(256))
+		}
+		parseHeader(data)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.1.110", "1.1.110", 0},
+		{"1.1.109", "1.1.110", -1},
+		{"2.0.0", "1.1.110", 1},
+		{"1.2.0", "1.10.0", -1},
+		{"0.18.47", "0.17.79", 1},
+	}
+	for _, tt := range tests {
+		a, err := ParseVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) = %v", tt.a, err)
+		}
+		b, err := ParseVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) = %v", tt.b, err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := a.String(); got != tt.a {
+			t.Errorf("String() = %q, want %q", got, tt.a)
+		}
+	}
+
+	if got := (Version{Major: 1, Minor: 1, Patch: 110, Build: 1}).Compare(Version{Major: 1, Minor: 1, Patch: 110, Build: 2}); got != 0 {
+		t.Errorf("Compare() of builds = %d, want 0", got)
+	}
+
+	for _, s := range []string{"", "1.1", "1.1.110.0", "1.a.110", "1.1.-1", "1.1.70000"} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("ParseVersion(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func writeSave(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	current := validHeader(true)
+	initial := headerUpToMods(&header{}).optimized(1).string("base").u8(1).u8(1).u8(110).u32(0).Bytes()
+
+	tests := []struct {
+		name    string
+		entries map[string][]byte
+		source  string
+		version string
+		err     error
+	}{
+		{name: "current map", entries: map[string][]byte{"world/level.dat0": current}, source: "level.dat0", version: "2.0.28"},
+		{name: "compressed current map", entries: map[string][]byte{"world/level.dat0": compress(current)}, source: "level.dat0", version: "2.0.28"},
+		{name: "current before initial map", entries: map[string][]byte{"world/level-init.dat": initial, "world/level.dat0": current}, source: "level.dat0", version: "2.0.28"},
+		{name: "old current map before initial map", entries: map[string][]byte{"world/level-init.dat": initial, "world/level.dat": current}, source: "level.dat", version: "2.0.28"},
+		{name: "initial map only", entries: map[string][]byte{"world/level-init.dat": initial}, source: InitialMapFile, version: "1.1.110"},
+		{name: "no map data", entries: map[string][]byte{"world/control.lua": []byte("")}, err: ErrNoHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "world.zip")
+			writeSave(t, path, tt.entries)
+
+			info, err := Read(path)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Read() = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() = %v", err)
+			}
+			if info.Source != tt.source || info.Version.String() != tt.version {
+				t.Errorf("Read() = %s from %s, want %s from %s", info.Version, info.Source, tt.version, tt.source)
+			}
+			if got, want := info.FromInitialMap(), tt.source == InitialMapFile; got != want {
+				t.Errorf("FromInitialMap() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string][]byte{
+		"empty entry":      nil,
+		"one byte":         {0x78},
+		"truncated zlib":   compress(validHeader(true))[:10],
+		"truncated header": validHeader(true)[:20],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".zip")
+			writeSave(t, path, map[string][]byte{"world/level.dat0": data})
+			if info, err := Read(path); err == nil {
+				t.Errorf("Read() = %+v, want an error", info)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "not a zip.zip")
+	os.WriteFile(path, []byte("not a zip"), 0644)
+	if _, err := Read(path); err == nil {
+		t.Error("Read() of a file that is not a zip succeeded")
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+// HTTP handler functions for managing API keys used by scripts and CI pipelines.
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+// maxAPIKeyNameLength bounds the length of API key names.
+const maxAPIKeyNameLength = 64
+
+// handleListAPIKeys returns the API keys created by the current admin, or all keys
+// for admins allowed to manage other admins. Key hashes are never included.
+func (s *RestServer) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	user := currentUser(r)
+	if auth.HasPermission(s.roleOf(user), auth.PermAdmins) {
+		user = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.apiKeys.List(user))
+}
+
+// handleCreateAPIKey creates an API key for the current admin.
+// Expects a JSON body with "name", "scopes" and an optional RFC 3339 "expires".
+// Scopes are limited to permissions held by the admin's role. The key itself is
+// only returned by this call.
+func (s *RestServer) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	var payload struct {
+		Expires string            `json:"expires"`
+		Name    string            `json:"name"`
+		Scopes  []auth.Permission `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" || len(payload.Name) > maxAPIKeyNameLength {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid name")
+		return
+	}
+
+	if len(payload.Scopes) == 0 {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	role := s.roleOf(currentUser(r))
+	for _, scope := range payload.Scopes {
+		if !auth.IsValidScope(scope) {
+			helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid scope: "+string(scope))
+			return
+		}
+		if !auth.HasPermission(role, scope) {
+			helpers.RenderErrorJSON(w, http.StatusForbidden, "Scope not permitted for your role: "+string(scope))
+			return
+		}
+	}
+
+	var expires *time.Time
+	if payload.Expires != "" {
+		t, err := time.Parse(time.RFC3339, payload.Expires)
+		if err != nil || !t.After(time.Now()) {
+			helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid expires")
+			return
+		}
+		expires = &t
+	}
+
+	secret, key, err := s.apiKeys.Create(payload.Name, currentUser(r), payload.Scopes, expires)
+	if err != nil {
+		log.Printf("Failed to create API key: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to create API key")
+		return
+	}
+	log.Printf("API key %q created by %s\n", key.Name, key.CreatedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		auth.APIKey
+		Key string `json:"key"`
+	}{key, secret})
+}
+
+// handleRevokeAPIKey deletes an API key. Admins may revoke their own keys; revoking
+// keys of other admins requires the admins permission.
+func (s *RestServer) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	key, err := s.apiKeys.Get(id)
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	user := currentUser(r)
+	if key.CreatedBy != user && !auth.HasPermission(s.roleOf(user), auth.PermAdmins) {
+		helpers.RenderErrorJSON(w, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	if err := s.apiKeys.Revoke(id); err != nil && !errors.Is(err, auth.ErrAPIKeyNotFound) {
+		log.Printf("Failed to revoke API key: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to revoke API key")
+		return
+	}
+	log.Printf("API key %q revoked by %s\n", key.Name, user)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireSession rejects requests authenticated with an API key, so keys cannot
+// be used to mint or revoke other keys.
+func (s *RestServer) requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := requestAPIKey(r); ok {
+		helpers.RenderErrorJSON(w, http.StatusForbidden, "API keys cannot manage API keys")
+		return false
+	}
+	return true
+}
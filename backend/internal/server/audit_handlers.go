@@ -0,0 +1,272 @@
+package server
+
+// The audit middleware recording mutating API calls, and the HTTP handler for
+// querying the audit log.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/audit"
+	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/config"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+const (
+	forbiddenAction    = "auth.forbidden" // Audit action recorded for permission denials
+	maxAuditBody       = 64 << 10         // Largest JSON body whose fields are recorded
+	maxAuditDepth      = 3                // Nesting depth of JSON fields recorded
+	maxAuditMessage    = 4 << 10          // Largest error response read for its message
+	maxAuditParamValue = 256              // Longest parameter value recorded
+)
+
+// sensitiveParams are substrings of parameter names whose values are never recorded.
+var sensitiveParams = []string{"code", "key", "password", "secret", "token"}
+
+// statusRecorder captures the status code and, for failed requests, the start of
+// the response body so the error message can be audited.
+type statusRecorder struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.status >= http.StatusBadRequest && r.body.Len() < maxAuditMessage {
+		r.body.Write(b[:min(len(b), maxAuditMessage-r.body.Len())])
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// withAudit records every call of next in the audit log under action, along with
+// the route parameters, query, non-sensitive JSON body fields and the outcome.
+// It must be wrapped by withAuth so the admin is known.
+func (s *RestServer) withAudit(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := auditParams(r)
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		if r.MultipartForm != nil {
+			for field, files := range r.MultipartForm.File {
+				for _, file := range files {
+					params[field] = file.Filename
+				}
+			}
+		}
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		entry := audit.Entry{
+			Action:  action,
+			IP:      s.clientIP(r),
+			Outcome: outcomeOf(status),
+			Params:  params,
+			Route:   r.Method + " " + routeTemplate(r),
+			Status:  status,
+			User:    currentUser(r),
+		}
+		if status >= http.StatusBadRequest {
+			entry.Detail = errorMessage(rec.body.Bytes(), status)
+		}
+		if key, ok := requestAPIKey(r); ok {
+			entry.Params["api_key"] = key.Name
+		}
+		if err := s.audit.Record(entry); err != nil {
+			log.Printf("Failed to write audit log: %v\n", err)
+		}
+	}
+}
+
+// recordForbidden audits a request rejected because the admin's role or API key
+// lacks the route's permission.
+func (s *RestServer) recordForbidden(r *http.Request, username string, perm auth.Permission) {
+	entry := audit.Entry{
+		Action:  forbiddenAction,
+		Detail:  "missing permission " + string(perm),
+		IP:      s.clientIP(r),
+		Outcome: audit.OutcomeDenied,
+		Route:   r.Method + " " + routeTemplate(r),
+		Status:  http.StatusForbidden,
+		User:    username,
+	}
+	if err := s.audit.Record(entry); err != nil {
+		log.Printf("Failed to write audit log: %v\n", err)
+	}
+}
+
+// handleAudit returns audit log entries, newest first.
+// Accepts optional "user", "action" (a trailing "*" matches a prefix), "q",
+// "from", "to" (RFC 3339) and "limit" query parameters.
+func (s *RestServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := audit.Filter{
+		Action:   params.Get("action"),
+		Contains: params.Get("q"),
+		Limit:    queryLimit(r, defaultSearchLimit, maxSearchLimit),
+		User:     params.Get("user"),
+	}
+
+	var err error
+	if filter.From, err = parseQueryTime(params.Get("from")); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid from time")
+		return
+	}
+	if filter.To, err = parseQueryTime(params.Get("to")); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid to time")
+		return
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		log.Printf("Failed to read audit log: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read audit log")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// auditRotation converts the [audit] config into rotation limits.
+func auditRotation(cfg config.AuditConfig) audit.Rotation {
+	return audit.Rotation{
+		MaxFiles: cfg.MaxFiles,
+		MaxSize:  int64(cfg.MaxSize) << 20,
+	}
+}
+
+// auditParams collects the route variables, query parameters and, for JSON
+// requests, the body fields of r. The body is restored for the handler.
+func auditParams(r *http.Request) map[string]string {
+	params := map[string]string{}
+	for k, v := range mux.Vars(r) {
+		addAuditParam(params, k, v)
+	}
+	for k, v := range r.URL.Query() {
+		addAuditParam(params, k, strings.Join(v, ","))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || mediaType != "application/json" {
+		return params
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBody+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	if err != nil || len(data) > maxAuditBody {
+		return params
+	}
+
+	var body any
+	if json.Unmarshal(data, &body) == nil {
+		flattenAuditParams(params, "", body, 0)
+	}
+	return params
+}
+
+// flattenAuditParams records the scalar fields of a decoded JSON value using
+// dotted names for nested objects.
+func flattenAuditParams(params map[string]string, name string, value any, depth int) {
+	switch v := value.(type) {
+	case map[string]any:
+		if depth >= maxAuditDepth {
+			return
+		}
+		for k, child := range v {
+			if name != "" {
+				k = name + "." + k
+			}
+			flattenAuditParams(params, k, child, depth+1)
+		}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				continue
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		addAuditParam(params, name, strings.Join(values, ","))
+	case nil:
+	default:
+		addAuditParam(params, name, fmt.Sprint(v))
+	}
+}
+
+// addAuditParam records a parameter unless its name suggests a credential.
+func addAuditParam(params map[string]string, name, value string) {
+	if name == "" {
+		name = "body"
+	}
+	lower := strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	for _, sensitive := range sensitiveParams {
+		if strings.Contains(lower, sensitive) {
+			return
+		}
+	}
+	if len(value) > maxAuditParamValue {
+		value = value[:maxAuditParamValue] + "…"
+	}
+	params[name] = value
+}
+
+// outcomeOf maps an HTTP status to an audit outcome.
+func outcomeOf(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return audit.OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return audit.OutcomeError
+	}
+	return audit.OutcomeOK
+}
+
+// errorMessage extracts the message of a JSON error response, falling back to
+// the status text.
+func errorMessage(body []byte, status int) string {
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+		return payload.Message
+	}
+	if text := strings.TrimSpace(string(body)); text != "" && len(body) < maxAuditMessage {
+		return text
+	}
+	return http.StatusText(status)
+}
+
+// routeTemplate returns the path template of the matched route, e.g. "/saves/{name}".
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.URL.Path
+}
@@ -0,0 +1,201 @@
+package server
+
+// HTTP handler functions for logging FSM admins in and out with session tokens.
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/snarf-dev/fsm/v2/internal/audit"
+	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/config"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+const (
+	loginAction   = "auth.login"  // Audit action recorded for password logins
+	sessionCookie = "fsm_session" // Name of the cookie carrying the session token
+)
+
+// handleLogin checks the credentials in the JSON body ("username", "password" and,
+// for admins with two-factor authentication, "code"), starts a session and returns
+// its token. The token is also set as an HttpOnly cookie.
+func (s *RestServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Code     string `json:"code"`
+		Password string `json:"password"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if wait, ok := s.checkPassword(r, payload.Username, payload.Password); wait > 0 {
+		renderTooManyAttempts(w, wait)
+		return
+	} else if !ok {
+		helpers.RenderErrorJSON(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	if _, enrolled := s.config().AdminTOTPSecret(payload.Username); enrolled {
+		if payload.Code == "" {
+			s.limiter.Release(s.clientIP(r), payload.Username)
+			renderTOTPRequired(w, totpRequiredReason)
+			return
+		}
+		if !s.verifySecondFactor(payload.Username, payload.Code) {
+			s.failLogin(r, payload.Username, "invalid second factor")
+			renderTOTPRequired(w, "Invalid two-factor code")
+			return
+		}
+	}
+	s.limiter.Succeed(s.clientIP(r), payload.Username)
+
+	token, session, err := s.sessions.Create(payload.Username, s.clientIP(r))
+	if err != nil {
+		log.Printf("Failed to create session: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to create session")
+		return
+	}
+
+	s.recordLogin(r, payload.Username, audit.OutcomeOK, "")
+
+	http.SetCookie(w, &http.Cookie{
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Name:     sessionCookie,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+		Value:    token,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"expires":  session.Expires,
+		"role":     s.roleOf(payload.Username),
+		"token":    token,
+		"username": payload.Username,
+	})
+}
+
+// handleLogout revokes the session used to authenticate the request, or every
+// session of the current admin when ?all=true is given.
+func (s *RestServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if r.URL.Query().Get("all") == "true" {
+		err = s.sessions.RevokeUser(currentUser(r))
+	} else if token, ok := r.Context().Value(tokenContextKey).(string); ok {
+		err = s.sessions.Revoke(token)
+	}
+	if err != nil {
+		log.Printf("Failed to revoke session: %v\n", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		HttpOnly: true,
+		MaxAge:   -1,
+		Name:     sessionCookie,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPassword verifies a username and password subject to login throttling.
+// When the client or username is currently throttled, the password is not checked
+// and the time to wait before the next attempt is returned instead. A wrong
+// password is counted as a failure; after a correct one the attempt stays pending
+// and callers must pass it to the limiter's Succeed, Fail or Release once every
+// factor has been checked.
+func (s *RestServer) checkPassword(r *http.Request, username, password string) (time.Duration, bool) {
+	ip := s.clientIP(r)
+	if wait := s.limiter.Allow(ip, username); wait > 0 {
+		log.Printf("Throttled login for %q from %s, retry in %s\n", username, ip, wait.Round(time.Second))
+		return wait, false
+	}
+
+	if hash, ok := s.config().AdminPasswordHash(username); ok && auth.CheckPassword(hash, password) {
+		return 0, true
+	}
+	s.failLogin(r, username, "invalid credentials")
+	return 0, false
+}
+
+// failLogin counts a failed login towards throttling, logs it and records it in
+// the audit log.
+func (s *RestServer) failLogin(r *http.Request, username, detail string) {
+	ip := s.clientIP(r)
+	if s.limiter.Fail(ip, username) {
+		detail += ", locked out"
+		log.Printf("Locked out login for %q from %s after repeated failures\n", username, ip)
+	} else {
+		log.Printf("Failed login for %q from %s: %s\n", username, ip, detail)
+	}
+	s.recordLogin(r, username, audit.OutcomeDenied, detail)
+}
+
+// recordLogin writes a login attempt to the audit log.
+func (s *RestServer) recordLogin(r *http.Request, username, outcome, detail string) {
+	err := s.audit.Record(audit.Entry{
+		Action:  loginAction,
+		Detail:  detail,
+		IP:      s.clientIP(r),
+		Outcome: outcome,
+		User:    username,
+	})
+	if err != nil {
+		log.Printf("Failed to write audit log: %v\n", err)
+	}
+}
+
+// renderTooManyAttempts rejects a throttled login with 429 and a Retry-After header.
+func renderTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	helpers.RenderErrorJSON(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+}
+
+// limiterPolicy converts the [login] config into a limiter policy.
+func limiterPolicy(cfg config.LoginConfig) auth.LimiterPolicy {
+	return auth.LimiterPolicy{
+		DelayAfter:      cfg.DelayAfter,
+		LockoutAfter:    cfg.LockoutAfter,
+		LockoutDuration: time.Duration(cfg.LockoutDuration) * time.Second,
+	}
+}
+
+// requestToken extracts the session token from the Authorization header, the
+// session cookie or, for WebSocket handshakes where browsers cannot set headers,
+// the "token" query parameter. Query strings end up in proxy and access logs, so
+// no other request may carry a token there, and API keys, which are long-lived
+// and used by clients able to set headers, are never accepted from it.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		return ""
+	}
+	if token := r.URL.Query().Get("token"); !strings.HasPrefix(token, auth.APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// revokeSessions ends every session of user, logging failures.
+func (s *RestServer) revokeSessions(user string) {
+	if err := s.sessions.RevokeUser(user); err != nil {
+		log.Printf("Failed to revoke sessions of %s: %v\n", user, err)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestToken(t *testing.T) {
+	upgrade := map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		cookie  string
+		want    string
+	}{
+		{name: "none", url: "/status"},
+		{name: "bearer header", url: "/status", headers: map[string]string{"Authorization": "Bearer  abc "}, want: "abc"},
+		{name: "basic header ignored", url: "/status", headers: map[string]string{"Authorization": "Basic abc"}},
+		{name: "cookie", url: "/status", cookie: "abc", want: "abc"},
+		{name: "header before cookie", url: "/status", headers: map[string]string{"Authorization": "Bearer abc"}, cookie: "def", want: "abc"},
+		{name: "query ignored without upgrade", url: "/status?token=abc"},
+		{name: "query on websocket upgrade", url: "/ws/logs?token=abc", headers: upgrade, want: "abc"},
+		{name: "cookie before query on upgrade", url: "/ws/logs?token=abc", headers: upgrade, cookie: "def", want: "def"},
+		{name: "api key in query rejected", url: "/ws/logs?token=fsm_id_secret", headers: upgrade},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+			if got := requestToken(r); got != tt.want {
+				t.Errorf("requestToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
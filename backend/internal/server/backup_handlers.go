@@ -0,0 +1,112 @@
+package server
+
+// HTTP handler functions for listing, taking, downloading and restoring backups
+// of Factorio save files.
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/backups"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+// handleListBackups returns the save backups, newest first.
+func (s *RestServer) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	list, err := backups.List(s.config().Backups.Dir)
+	if err != nil {
+		log.Printf("Failed to read %s: %v\n", s.config().Backups.Dir, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to list backups")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleCreateBackup takes a backup of the active save immediately.
+func (s *RestServer) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	backup, err := s.manager.Backup(backups.ReasonManual)
+	if errors.Is(err, errNoSave) {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "No save to back up")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to back up save: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to back up save")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(backup)
+}
+
+// handleDownloadBackup streams the named backup to the client as a file download.
+func (s *RestServer) handleDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	_, path, err := backups.Get(s.config().Backups.Dir, name)
+	if err != nil {
+		renderBackupError(w, err, "Failed to read backup")
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	http.ServeFile(w, r, path)
+}
+
+// handleRestoreBackup replaces the save a backup was taken from with the backup.
+// The server must be stopped. The save being replaced is backed up first, and
+// when a save is explicitly selected the restored save becomes the selection.
+func (s *RestServer) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	backup, _, err := backups.Get(s.config().Backups.Dir, name)
+	if err != nil {
+		renderBackupError(w, err, "Failed to read backup")
+		return
+	}
+
+	if err := s.manager.BeginUpdate("restore backup"); err != nil {
+		renderManagerError(w, err, "Failed to restore backup")
+		return
+	}
+	defer s.manager.EndUpdate()
+
+	current := filepath.Join(s.config().Factorio.SavesDir, backup.Save)
+	if _, err := backups.Snapshot(s.config().Backups.Dir, current, backups.ReasonRestore, time.Now()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to back up %s before restoring: %v\n", backup.Save, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to back up current save")
+		return
+	}
+
+	if _, err := backups.Restore(s.config().Backups.Dir, name, s.config().Factorio.SavesDir); err != nil {
+		log.Printf("Failed to restore %s: %v\n", name, err)
+		renderBackupError(w, err, "Failed to restore backup")
+		return
+	}
+	log.Printf("Restored backup %s to %s\n", name, backup.Save)
+
+	if s.config().Factorio.Save != "" && s.config().Factorio.Save != backup.Save {
+		s.config().Factorio.Save = backup.Save
+		if err := s.config().SaveToFile(); err != nil {
+			log.Printf("failed to update %s, %v\n", s.config().Path, err)
+			helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backup)
+}
+
+// renderBackupError maps a backups package error to a response.
+func renderBackupError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, backups.ErrNotFound) {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Backup not found")
+		return
+	}
+	helpers.RenderErrorJSON(w, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,201 @@
+package server
+
+// Backups of the active save, taken on a schedule and around start, stop and
+// version switches, and the backup retention policy.
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/snarf-dev/fsm/v2/internal/backups"
+	"github.com/snarf-dev/fsm/v2/internal/config"
+)
+
+const (
+	backupCheckInterval   = time.Minute      // How often the backup schedule is checked
+	backupSaveTimeout     = 30 * time.Second // Time allowed for /server-save to write the save
+	shutdownBackupTimeout = time.Minute      // Time FSM waits on exit for a background backup to finish
+)
+
+// errNoSave is returned when there is no save file to back up.
+var errNoSave = errors.New("no save to back up")
+
+// Backup snapshots the active save into the backups directory. When the server
+// is running and RCON is enabled the map is saved first so the backup is current.
+func (s *ServerManager) Backup(reason string) (backups.Backup, error) {
+	s.mu.Lock()
+	running := s.state == StateRunning
+	s.mu.Unlock()
+
+	if running && s.config().RCon.Enabled {
+		s.saveForBackup()
+	}
+	return s.backup(reason)
+}
+
+// backupBefore snapshots the active save around an operation that may change
+// it, such as a start or version switch, or after a stop. Failures are logged
+// and do not block the operation. It does not take s.mu.
+func (s *ServerManager) backupBefore(reason string) {
+	if _, err := s.backup(reason); err != nil && !errors.Is(err, errNoSave) {
+		log.Printf("Failed to back up save before %s: %v\n", reason, err)
+	}
+}
+
+// backupBeforeStart snapshots the active save unless the server is already
+// running. It must be called without holding s.mu, so copying a large save does
+// not block status requests and other manager calls.
+func (s *ServerManager) backupBeforeStart() {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		s.backupBefore(backups.ReasonStart)
+	}
+}
+
+// waitForBackups waits until backups running in the background have finished,
+// or until timeout passes, so FSM does not exit halfway through copying a save.
+func (s *ServerManager) waitForBackups(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.backupsRunning.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Backup did not finish within %s, exiting anyway\n", timeout)
+	}
+}
+
+// backup copies the active save into the backups directory and prunes old
+// backups. It does not take s.mu.
+func (s *ServerManager) backup(reason string) (backups.Backup, error) {
+	s.backupMu.Lock()
+	defer s.backupMu.Unlock()
+
+	cfg := s.config()
+	path, err := activeSavePath(cfg.Factorio.SavesDir, cfg.Factorio.Save)
+	if err != nil {
+		return backups.Backup{}, err
+	}
+
+	backup, err := backups.Snapshot(cfg.Backups.Dir, path, reason, time.Now())
+	if err != nil {
+		return backups.Backup{}, err
+	}
+	s.lastBackup = time.Now()
+	log.Printf("Backed up %s to %s\n", path, backup.Name)
+
+	if err := backups.Prune(cfg.Backups.Dir, backupRetention(cfg.Backups)); err != nil {
+		log.Printf("Failed to prune backups in %s: %v\n", cfg.Backups.Dir, err)
+	}
+	return backup, nil
+}
+
+// saveForBackup saves the map over RCON and waits until the save file has been
+// rewritten, or until backupSaveTimeout passes.
+func (s *ServerManager) saveForBackup() {
+	path, err := activeSavePath(s.config().Factorio.SavesDir, s.config().Factorio.Save)
+	if err != nil {
+		return
+	}
+	before, _ := os.Stat(path)
+
+	if _, err := s.rcon.Execute("/server-save"); err != nil {
+		log.Printf("Failed to save before backup: %v\n", err)
+		return
+	}
+
+	deadline := time.Now().Add(backupSaveTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		after, err := os.Stat(path)
+		if err == nil && (before == nil || after.ModTime().After(before.ModTime())) {
+			// Give Factorio a moment to finish writing before the file is copied.
+			time.Sleep(time.Second)
+			return
+		}
+	}
+	log.Printf("Save %s was not updated within %s, backing up the previous save\n", path, backupSaveTimeout)
+}
+
+// backupPeriodically takes a scheduled backup whenever the server is running and
+// the configured interval has passed since the last backup.
+func (s *ServerManager) backupPeriodically() {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		interval := time.Duration(s.config().Backups.Interval) * time.Minute
+
+		s.mu.Lock()
+		running := s.state == StateRunning
+		s.mu.Unlock()
+		s.backupMu.Lock()
+		due := time.Since(s.lastBackup) >= interval
+		s.backupMu.Unlock()
+
+		if interval <= 0 || !running || !due {
+			continue
+		}
+		if _, err := s.Backup(backups.ReasonScheduled); err != nil && !errors.Is(err, errNoSave) {
+			log.Printf("Scheduled backup failed: %v\n", err)
+		}
+	}
+}
+
+// activeSavePath returns the save the server loads: the configured save, or the
+// most recently modified save when none is configured.
+func activeSavePath(savesDir string, save string) (string, error) {
+	if save != "" {
+		path := filepath.Join(savesDir, save)
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return "", errNoSave
+			}
+			return "", err
+		}
+		return path, nil
+	}
+
+	entries, err := os.ReadDir(savesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errNoSave
+		}
+		return "", err
+	}
+	var latest string
+	var latestTime time.Time
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".zip") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = entry.Name(), info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", errNoSave
+	}
+	return filepath.Join(savesDir, latest), nil
+}
+
+// backupRetention converts the [backups] config into a retention policy.
+func backupRetention(cfg config.BackupsConfig) backups.Retention {
+	return backups.Retention{
+		KeepDaily:  cfg.KeepDaily,
+		KeepHourly: cfg.KeepHourly,
+		KeepLast:   cfg.KeepLast,
+		KeepWeekly: cfg.KeepWeekly,
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+// Structured events parsed from the Factorio log stream, published to
+// subscribers such as notifications and player tracking.
+
+import (
+	"github.com/snarf-dev/fsm/v2/internal/logparser"
+)
+
+// SubscribeToEvents returns a channel receiving every event parsed from the
+// server output along with a function that removes the subscription.
+func (s *ServerManager) SubscribeToEvents() (<-chan logparser.Event, func()) {
+	ch := make(chan logparser.Event, 100)
+	s.mu.Lock()
+	s.eventSubscribers = append(s.eventSubscribers, ch)
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.eventSubscribers {
+			if sub == ch {
+				s.eventSubscribers = append(s.eventSubscribers[:i], s.eventSubscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// publishEvent reacts to lifecycle events and forwards the event to all
+// subscribers. The caller must hold s.mu.
+func (s *ServerManager) publishEvent(event logparser.Event) {
+	if event.Type == logparser.EventStateChange && s.state == StateStarting &&
+		event.Details["from"] == "CreatingGame" && event.Details["to"] == "InGame" {
+		s.setState(StateRunning, "map loaded")
+	}
+
+	for _, ch := range s.eventSubscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
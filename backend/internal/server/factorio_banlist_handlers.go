@@ -11,17 +11,17 @@ import (
 
 // handleListFactorioBans returns the list of Factorio server bans as JSON.
 func (s *RestServer) handleListFactorioBans(w http.ResponseWriter, r *http.Request) {
-	helpers.HandleListUsernameFile(s.fsmConfig.Factorio.Files.BanList, w, r)
+	helpers.HandleListUsernameFile(s.config().Factorio.Files.BanList, w, r)
 }
 
 // handleAddFactorioBan adds a new username to the Factorio ban list if not already present.
 // It expects a JSON payload with a "username" field.
 func (s *RestServer) handleAddFactorioBanUser(w http.ResponseWriter, r *http.Request) {
-	helpers.HandleAddUsernameToFile(s.fsmConfig.Factorio.Files.BanList, w, r)
+	helpers.HandleAddUsernameToFile(s.config().Factorio.Files.BanList, w, r)
 }
 
 // handleRemoveFactorioBan removes the specified user from the Factorio ban list.
 // The username is taken from the URL path parameter.
 func (s *RestServer) handleRemoveFactorioBanUser(w http.ResponseWriter, r *http.Request) {
-	helpers.HandleRemoveUsernameFromFile(s.fsmConfig.Factorio.Files.BanList, w, r)
+	helpers.HandleRemoveUsernameFromFile(s.config().Factorio.Files.BanList, w, r)
 }
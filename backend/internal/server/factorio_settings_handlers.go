@@ -17,7 +17,7 @@ import (
 // handleGetServerSettings responds with the contents of the Factorio server-settings.json file.
 // It returns the raw JSON as-is from the configured file path.
 func (s *RestServer) handleGetServerSettings(w http.ResponseWriter, r *http.Request) {
-	path := filepath.Clean(s.fsmConfig.Factorio.Files.ServerSettings)
+	path := filepath.Clean(s.config().Factorio.Files.ServerSettings)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		log.Printf("Failed to read %s: %v\n", path, err)
@@ -34,7 +34,7 @@ func (s *RestServer) handleGetServerSettings(w http.ResponseWriter, r *http.Requ
 func (s *RestServer) handleUpdateServerSettings(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	path := filepath.Clean(s.fsmConfig.Factorio.Files.ServerSettings)
+	path := filepath.Clean(s.config().Factorio.Files.ServerSettings)
 	originalData, err := os.ReadFile(path)
 	if err != nil {
 		log.Printf("Failed to read %s: %v\n", path, err)
@@ -91,8 +91,8 @@ func (s *RestServer) handleUpdateServerSettings(w http.ResponseWriter, r *http.R
 // as a JSON object from the loaded FSM configuration.
 func (s *RestServer) handleGetFactorioUserSettings(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
-		"username": s.manager.cfg.Factorio.Username,
-		"token":    s.manager.cfg.Factorio.Token,
+		"username": s.manager.config().Factorio.Username,
+		"token":    s.manager.config().Factorio.Token,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -111,12 +111,12 @@ func (s *RestServer) handleUpdateFactorioUserSettings(w http.ResponseWriter, r *
 		return
 	}
 
-	s.fsmConfig.Factorio.Username = payload.Username
-	s.fsmConfig.Factorio.Token = payload.Token
+	s.config().Factorio.Username = payload.Username
+	s.config().Factorio.Token = payload.Token
 
-	err := s.fsmConfig.SaveToFile()
+	err := s.config().SaveToFile()
 	if err != nil {
-		log.Printf("failed to update %s, %v\n", s.fsmConfig.Path, err)
+		log.Printf("failed to update %s, %v\n", s.config().Path, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
 		return
 	}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/backups"
 	"github.com/snarf-dev/fsm/v2/internal/factorio"
 	"github.com/snarf-dev/fsm/v2/internal/helpers"
 )
@@ -22,7 +23,7 @@ func (s *RestServer) handleDownloadFactorioVersion(w http.ResponseWriter, r *htt
 	branch := vars["branch"]
 	version := vars["version"]
 
-	_, err := factorio.DownloadAndExtractVersion(s.fsmConfig, branch, version)
+	_, err := factorio.DownloadAndExtractVersion(s.config(), branch, version)
 	if err != nil {
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read API response")
 		return
@@ -36,26 +37,33 @@ func (s *RestServer) handleDownloadProgressStream(w http.ResponseWriter, r *http
 	branch := vars["branch"]
 	version := vars["version"]
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := s.upgrade(w, r)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v\n", err)
 		return
 	}
 	defer conn.Close()
 
-	progressCh := factorio.SubscribeDownloadProgress(branch, version)
-
-	for progress := range progressCh {
-		err := conn.WriteJSON(map[string]interface{}{
-			"type":    "progress",
-			"branch":  branch,
-			"version": version,
-			"percent": progress.Percent,
-			"stage":   progress.Stage,
-		})
-		if err != nil {
-			log.Printf("WebSocket write failed: %v\n", err)
-			break
+	progressCh, unsubscribe := factorio.SubscribeDownloadProgress(branch, version)
+	defer unsubscribe()
+
+	ctx := keepAlive(r.Context(), conn, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case progress := <-progressCh:
+			err := writeJSON(conn, map[string]interface{}{
+				"type":    "progress",
+				"branch":  branch,
+				"version": version,
+				"percent": progress.Percent,
+				"stage":   progress.Stage,
+			})
+			if err != nil {
+				log.Printf("WebSocket write failed: %v\n", err)
+				return
+			}
 		}
 	}
 }
@@ -77,7 +85,7 @@ func (s *RestServer) handleListFactorioVersions(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	installed, _ := factorio.GetInstalledFactorioVersions(s.fsmConfig.Factorio.ServerVersions)
+	installed, _ := factorio.GetInstalledFactorioVersions(s.config().Factorio.ServerVersions)
 
 	response := map[string]interface{}{
 		"available": json.RawMessage(buf.Bytes()),
@@ -95,7 +103,14 @@ func (s *RestServer) handleSelectFactorioVersion(w http.ResponseWriter, r *http.
 	branch := vars["branch"]
 	version := vars["version"]
 
-	err := factorio.SelectVersion(s.fsmConfig, branch, version)
+	if err := s.manager.BeginUpdate("switch version"); err != nil {
+		renderManagerError(w, err, "Failed to switch versions")
+		return
+	}
+	defer s.manager.EndUpdate()
+
+	s.manager.backupBefore(backups.ReasonVersion)
+	err := factorio.SelectVersion(s.config(), branch, version)
 	if err != nil {
 		log.Printf("Failed to switch version:%v\n", err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to switch versions")
@@ -110,7 +125,13 @@ func (s *RestServer) handleUninstallFactorioVersion(w http.ResponseWriter, r *ht
 	branch := vars["branch"]
 	version := vars["version"]
 
-	err := factorio.UninstallVersion(s.fsmConfig, branch, version)
+	if err := s.manager.BeginUpdate("uninstall version"); err != nil {
+		renderManagerError(w, err, "Failed to uninstall")
+		return
+	}
+	defer s.manager.EndUpdate()
+
+	err := factorio.UninstallVersion(s.config(), branch, version)
 	if err != nil {
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to uninstall")
 		return
@@ -0,0 +1,46 @@
+package server
+
+// HTTP handler functions for viewing and clearing login lockouts caused by
+// repeated failed attempts.
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+// handleListLockouts returns every IP and username with recorded failed logins,
+// including when active lockouts expire.
+func (s *RestServer) handleListLockouts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.limiter.Lockouts())
+}
+
+// handleClearLockouts forgets every recorded failed login.
+func (s *RestServer) handleClearLockouts(w http.ResponseWriter, r *http.Request) {
+	s.limiter.ClearAll()
+	log.Printf("All login lockouts cleared by %s\n", currentUser(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleClearLockout forgets the failed logins of a single IP or username.
+// The route variables are the kind ("ip" or "user") and the value.
+func (s *RestServer) handleClearLockout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kind, value := vars["kind"], vars["value"]
+	if kind != auth.LimitIP && kind != auth.LimitUser {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid kind")
+		return
+	}
+
+	if !s.limiter.Clear(kind, value) {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "No failed logins recorded")
+		return
+	}
+	log.Printf("Login lockout for %s %q cleared by %s\n", kind, value, currentUser(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package server
+
+// HTTP handlers for browsing the archived Factorio console logs, including
+// listing, downloading, paging and searching them.
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/logarchive"
+)
+
+const (
+	defaultLogPageSize = 500
+	maxLogPageSize     = 5000
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// handleListLogs returns every console log file with its size and time range.
+func (s *RestServer) handleListLogs(w http.ResponseWriter, r *http.Request) {
+	files, err := logarchive.List(s.config().Factorio.LogsDir)
+	if err != nil {
+		log.Printf("Failed to read %s: %v", s.config().Factorio.LogsDir, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to list logs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+// handleDownloadLog streams a console log file to the client as a file download.
+// The file name is passed as a URL path variable.
+func (s *RestServer) handleDownloadLog(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	path, err := logarchive.Path(s.config().Factorio.LogsDir, name)
+	if err != nil {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Log file not found")
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	http.ServeFile(w, r, path)
+}
+
+// handleReadLog returns a page of lines from a console log file.
+// Accepts optional "offset" and "limit" query parameters.
+func (s *RestServer) handleReadLog(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	limit := queryLimit(r, defaultLogPageSize, maxLogPageSize)
+
+	page, err := logarchive.ReadPage(s.config().Factorio.LogsDir, name, offset, limit)
+	if err != nil {
+		log.Printf("Failed to read log %s: %v", name, err)
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Log file not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// handleSearchLogs searches all console log files for a substring or regular expression.
+// Accepts "q", "regex", "from", "to" (RFC 3339) and "limit" query parameters.
+func (s *RestServer) handleSearchLogs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := logarchive.Query{
+		Limit:   queryLimit(r, defaultSearchLimit, maxSearchLimit),
+		Pattern: params.Get("q"),
+		Regex:   params.Get("regex") == "true",
+	}
+
+	var err error
+	if query.From, err = parseQueryTime(params.Get("from")); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid from time")
+		return
+	}
+	if query.To, err = parseQueryTime(params.Get("to")); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid to time")
+		return
+	}
+
+	matches, err := logarchive.Search(s.config().Factorio.LogsDir, query)
+	if err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
+
+// queryLimit reads the "limit" query parameter, applying a default and an upper bound.
+func queryLimit(r *http.Request, def int, max int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// parseQueryTime parses an optional RFC 3339 query parameter.
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
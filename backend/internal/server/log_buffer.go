@@ -0,0 +1,78 @@
+package server
+
+// A bounded history of recent Factorio log lines, so that late subscribers can
+// replay output produced before they connected.
+
+import "time"
+
+// defaultLogHistory is used when log_history is not configured.
+const defaultLogHistory = 1000
+
+// LogLine is a single line of Factorio output tagged with a sequence number
+// and the time it was received.
+type LogLine struct {
+	Seq  uint64    `json:"seq"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// LogStats reports log streaming metrics for /status.
+type LogStats struct {
+	Dropped     uint64 `json:"dropped"`
+	LastSeq     uint64 `json:"last_seq"`
+	Subscribers int    `json:"subscribers"`
+}
+
+// logBuffer is a fixed-size ring buffer of log lines ordered by sequence number.
+type logBuffer struct {
+	lines []LogLine
+	next  int
+	full  bool
+}
+
+// newLogBuffer creates a ring buffer holding at most size lines.
+func newLogBuffer(size int) *logBuffer {
+	if size <= 0 {
+		size = defaultLogHistory
+	}
+	return &logBuffer{lines: make([]LogLine, size)}
+}
+
+// add appends a line, overwriting the oldest one when the buffer is full.
+func (b *logBuffer) add(line LogLine) {
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// all returns the buffered lines from oldest to newest.
+func (b *logBuffer) all() []LogLine {
+	if !b.full {
+		return append([]LogLine(nil), b.lines[:b.next]...)
+	}
+	out := make([]LogLine, 0, len(b.lines))
+	out = append(out, b.lines[b.next:]...)
+	return append(out, b.lines[:b.next]...)
+}
+
+// last returns up to n of the most recent lines, oldest first.
+func (b *logBuffer) last(n int) []LogLine {
+	lines := b.all()
+	if n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// since returns the buffered lines with a sequence number greater than seq.
+func (b *logBuffer) since(seq uint64) []LogLine {
+	lines := b.all()
+	for i, line := range lines {
+		if line.Seq > seq {
+			return lines[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package server
+
+// The console log retention policy, applied periodically to the logs directory.
+
+import (
+	"log"
+	"time"
+
+	"github.com/snarf-dev/fsm/v2/internal/logarchive"
+)
+
+// logPruneInterval is how often the retention policy is applied.
+const logPruneInterval = time.Hour
+
+// pruneLogs removes console logs outside the configured retention policy,
+// keeping the log of the current run.
+func (s *ServerManager) pruneLogs() {
+	s.mu.Lock()
+	dir, current, policy := s.config().Factorio.LogsDir, s.consoleLog, s.config().Logs
+	s.mu.Unlock()
+
+	if dir == "" {
+		return
+	}
+
+	retention := logarchive.Retention{
+		MaxAge:       time.Duration(policy.MaxAge) * 24 * time.Hour,
+		MaxTotalSize: int64(policy.MaxSize) * 1024 * 1024,
+	}
+	if err := logarchive.Prune(dir, retention, current); err != nil {
+		log.Printf("Failed to prune logs in %s: %v\n", dir, err)
+	}
+}
+
+// pruneLogsPeriodically applies the retention policy now and then on every interval.
+func (s *ServerManager) pruneLogsPeriodically() {
+	s.pruneLogs()
+	ticker := time.NewTicker(logPruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.pruneLogs()
+	}
+}
@@ -6,18 +6,24 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/snarf-dev/fsm/v2/internal/backups"
 	"github.com/snarf-dev/fsm/v2/internal/config"
 	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/logparser"
+	"github.com/snarf-dev/fsm/v2/internal/players"
 )
 
 type ServerVersion struct {
@@ -27,43 +33,138 @@ type ServerVersion struct {
 }
 
 type ServerStatus struct {
-	CanDownload  bool          `json:"can_download"`
-	IsConfigured bool          `json:"is_configured"`
-	Running      bool          `json:"running"`
-	Version      ServerVersion `json:"version"`
+	CanDownload  bool              `json:"can_download"`
+	Crashes      []CrashEvent      `json:"crashes"`
+	IsConfigured bool              `json:"is_configured"`
+	LastExit     *ExitStatus       `json:"last_exit,omitempty"`
+	RCon         RConHealth        `json:"rcon"`
+	Logs         LogStats          `json:"logs"`
+	NextRestart  *time.Time        `json:"next_restart,omitempty"`
+	Restarts     int               `json:"restarts"`
+	Running      bool              `json:"running"`
+	State        ServerState       `json:"state"`
+	StateSince   time.Time         `json:"state_since"`
+	Transitions  []StateTransition `json:"transitions"`
+	Version      ServerVersion     `json:"version"`
+}
+
+// ExitStatus records how the Factorio process terminated.
+type ExitStatus struct {
+	Code   int       `json:"code"`
+	Signal string    `json:"signal,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// StopResult describes the outcome of a shutdown performed by Stop.
+type StopResult struct {
+	Duration int64       `json:"duration_ms"`
+	Exit     *ExitStatus `json:"exit,omitempty"`
+	Killed   bool        `json:"killed"`
+	Saved    bool        `json:"saved"`
 }
 
 type ServerManager struct {
-	cfg            *config.FSMConfig
-	cmd            *exec.Cmd
-	mu             sync.Mutex
-	running        bool
-	logSubscribers []chan string
-	Version        ServerVersion
+	backupMu         sync.Mutex
+	backupsRunning   sync.WaitGroup                   // Backups taken in the background after a stop
+	cfg              atomic.Pointer[config.FSMConfig] // Replaced when the config file is reloaded
+	cmd              *exec.Cmd
+	consoleLog       string
+	crashes          []CrashEvent
+	done             chan struct{}
+	eventSubscribers []chan logparser.Event
+	lastBackup       time.Time
+	lastExit         *ExitStatus
+	logDropped       uint64
+	logHistory       *logBuffer
+	logSeq           uint64
+	mapSubscribers   []chan MapProgress
+	mu               sync.Mutex
+	players          *players.Tracker
+	rcon             *RConClient
+	nextRestart      *time.Time
+	restartAttempts  int
+	restartGen       int // Identifies the scheduled restart, so a cancelled timer can tell it is stale
+	restartTimer     *time.Timer
+	restarts         int
+	running          bool
+	startedAt        time.Time
+	state            ServerState
+	stateHistory     []StateTransition
+	stateSubscribers []chan StateTransition
+	stopRequested    bool
+	logSubscribers   []chan LogLine
+	Version          ServerVersion
+}
+
+// config returns the current FSM configuration.
+func (s *ServerManager) config() *config.FSMConfig {
+	return s.cfg.Load()
 }
 
+// defaultStopTimeout is used when stop_timeout is not configured.
+const defaultStopTimeout = 30 * time.Second
+
 // CreateManager initializes a new ServerManager, creating necessary directories
 // and setting the current server version based on the configured selection.
 func CreateManager(cfg *config.FSMConfig) *ServerManager {
 	manager := &ServerManager{
-		cfg: cfg,
+		logHistory: newLogBuffer(cfg.Factorio.LogHistory),
+		state:      StateStopped,
 	}
+	manager.cfg.Store(cfg)
 
+	manager.rcon = newRConClient(func() config.RConConfig { return manager.config().RCon })
 	manager.createFilesAndDirectories()
 	manager.Version = manager.GetVersion()
+	go manager.pruneLogsPeriodically()
+	go manager.backupPeriodically()
+
+	tracker, err := players.Open(filepath.Join(cfg.Server.DataDir, "players.json"))
+	if err != nil {
+		log.Printf("Failed to load player history, starting empty: %v\n", err)
+		tracker = players.NewTracker(filepath.Join(cfg.Server.DataDir, "players.json"))
+	}
+	manager.players = tracker
+	go manager.trackPlayers()
 	return manager
 }
 
 // Start launches the Factorio server using the configured version and options.
-// It sets up log streaming and tracks the running state.
+// It sets up log streaming and tracks the running state. A manual start cancels
+// any pending automatic restart and resets the retry counter. A ModMismatchError
+// is returned when the save uses mods that are missing or disabled.
 func (s *ServerManager) Start() error {
+	return s.start(false)
+}
+
+// StartForced starts the server like Start, but without checking the mods of
+// the save.
+func (s *ServerManager) StartForced() error {
+	return s.start(true)
+}
+
+func (s *ServerManager) start(force bool) error {
+	s.backupBeforeStart()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.cancelRestart()
+	s.restartAttempts = 0
+	return s.startLocked(force)
+}
+
+// startLocked launches the Factorio process, checking the mods of the save
+// first unless force is set. The caller must hold s.mu.
+func (s *ServerManager) startLocked(force bool) error {
 	if s.running {
 		return nil
 	}
 
+	if err := s.requireState("start", StateStopped, StateCrashed); err != nil {
+		return err
+	}
+
 	if !s.isConfigured() {
 		s.InitialiseConfiguration(false)
 		if !s.isConfigured() {
@@ -71,14 +172,20 @@ func (s *ServerManager) Start() error {
 		}
 	}
 
+	if !force {
+		if err := s.checkSaveMods(); err != nil {
+			return err
+		}
+	}
+
 	s.Version = s.GetVersion()
 
-	binaryPath := fmt.Sprintf("%s/%s/%s/factorio/bin/x64/factorio",
-		s.cfg.Factorio.ServerVersions, s.cfg.Factorio.SelectedBranch, s.cfg.Factorio.SelectedVersion)
+	binaryPath := s.binaryPath()
 	if !helpers.FileExists(binaryPath) {
 		return fmt.Errorf("%s does not exist", binaryPath)
 	}
 	cmd := exec.Command(binaryPath, s.buildArgs()...)
+	go s.pruneLogs()
 
 	stdout, _ := cmd.StdoutPipe()
 	stderr, _ := cmd.StderrPipe()
@@ -92,13 +199,28 @@ func (s *ServerManager) Start() error {
 		return err
 	}
 
+	done := make(chan struct{})
 	s.cmd = cmd
+	s.done = done
 	s.running = true
+	s.startedAt = time.Now()
+	s.stopRequested = false
+	s.setState(StateStarting, "process started")
 	go func() {
 		cmd.Wait()
+		exit := exitStatusOf(cmd.ProcessState)
+		log.Printf("Server exited with code %d %s\n", exit.Code, exit.Signal)
 		s.mu.Lock()
 		s.running = false
+		s.lastExit = &exit
+		if s.stopRequested {
+			s.setState(StateStopped, "process exited")
+		} else {
+			s.setState(StateCrashed, "process exited unexpectedly")
+			s.handleUnexpectedExit(exit)
+		}
 		s.mu.Unlock()
+		close(done)
 	}()
 
 	log.Println("Server started")
@@ -107,58 +229,198 @@ func (s *ServerManager) Start() error {
 }
 
 // SubscribeToLogs allows external consumers to receive log output lines from the server.
-// It returns a channel that receives log lines as strings.
-func (s *ServerManager) SubscribeToLogs() <-chan string {
-	ch := make(chan string, 100)
+// It returns the buffered lines to replay, a channel receiving new lines and a function
+// that removes the subscription. When since is non-zero the replay contains every
+// buffered line after that sequence number, allowing a client to resume without
+// duplicates; otherwise the last n lines are replayed.
+func (s *ServerManager) SubscribeToLogs(since uint64, n int) ([]LogLine, <-chan LogLine, func()) {
+	ch := make(chan LogLine, 100)
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replay []LogLine
+	if since > 0 {
+		replay = s.logHistory.since(since)
+	} else if n > 0 {
+		replay = s.logHistory.last(n)
+	}
 	s.logSubscribers = append(s.logSubscribers, ch)
-	s.mu.Unlock()
-	return ch
+	return replay, ch, func() { s.unsubscribeFromLogs(ch) }
 }
 
-// Stop attempts to gracefully terminate the running Factorio server process.
-func (s *ServerManager) Stop() error {
+// unsubscribeFromLogs removes a channel previously returned by SubscribeToLogs.
+func (s *ServerManager) unsubscribeFromLogs(ch chan LogLine) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	for i, sub := range s.logSubscribers {
+		if sub == ch {
+			s.logSubscribers = append(s.logSubscribers[:i], s.logSubscribers[i+1:]...)
+			return
+		}
+	}
+}
 
+// Stop gracefully shuts down the running Factorio server process. Players are
+// warned and the map is saved over RCON when configured, then SIGTERM is sent.
+// If the process has not exited within the stop timeout it is killed. Stop only
+// returns once the process has actually exited.
+func (s *ServerManager) Stop() (StopResult, error) {
+	s.mu.Lock()
 	if !s.running || s.cmd == nil {
+		if s.cancelRestart() {
+			log.Println("Cancelled pending restart")
+			s.setState(StateStopped, "restart cancelled")
+		}
+		s.mu.Unlock()
 		log.Println("Server not running")
-		return nil
+		return StopResult{}, nil
+	}
+	if err := s.requireState("stop", StateStarting, StateRunning); err != nil {
+		s.mu.Unlock()
+		return StopResult{}, err
 	}
+	s.stopRequested = true
+	s.setState(StateStopping, "stop requested")
+	cmd, done := s.cmd, s.done
+	s.mu.Unlock()
 
-	err := s.cmd.Process.Signal(syscall.SIGTERM)
-	if err != nil {
+	started := time.Now()
+	result := StopResult{Saved: s.prepareForStop()}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
 		log.Printf("Error stopping server: %v\n", err)
-		return err
+		return result, err
 	}
 
-	log.Println("Server stopped")
+	timeout := s.stopTimeout()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Server did not exit within %s, killing\n", timeout)
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			log.Printf("Error killing server: %v\n", err)
+			return result, err
+		}
+		result.Killed = true
+		<-done
+	}
+
+	s.mu.Lock()
+	result.Exit = s.lastExit
+	s.mu.Unlock()
+	result.Duration = time.Since(started).Milliseconds()
 
-	s.running = false
+	log.Printf("Server stopped in %dms\n", result.Duration)
+	// Back up once the process has exited, so the backup includes the save
+	// written by /server-save and on shutdown. Copying a large save can take a
+	// while, so it runs in the background instead of delaying the response.
+	s.backupsRunning.Add(1)
+	go func() {
+		defer s.backupsRunning.Done()
+		s.backupBefore(backups.ReasonStop)
+	}()
+	return result, nil
+}
+
+// Shutdown stops the Factorio server because FSM is exiting and closes any player
+// sessions left open. It returns an error if the server could not be stopped
+// cleanly.
+func (s *ServerManager) Shutdown() error {
+	result, err := s.Stop()
+	if endErr := s.players.EndAll(time.Now()); endErr != nil {
+		log.Printf("Failed to close player sessions: %v\n", endErr)
+	}
+	s.waitForBackups(shutdownBackupTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to stop server: %w", err)
+	}
+	if result.Killed {
+		return fmt.Errorf("server did not exit within %s and was killed", s.stopTimeout())
+	}
 	return nil
 }
 
+// prepareForStop announces the shutdown to players and saves the map over RCON
+// when configured. It returns true if the save command was accepted.
+func (s *ServerManager) prepareForStop() bool {
+	if !s.config().RCon.Enabled {
+		return false
+	}
+
+	if s.config().Factorio.StopMessage != "" {
+		if _, err := s.rcon.Execute(s.config().Factorio.StopMessage); err != nil {
+			log.Printf("Failed to announce shutdown: %v\n", err)
+		}
+	}
+
+	if !s.config().Factorio.SaveOnStop {
+		return false
+	}
+
+	if _, err := s.rcon.Execute("/server-save"); err != nil {
+		log.Printf("Failed to save before stopping: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// stopTimeout returns how long Stop waits for the process to exit before killing it.
+func (s *ServerManager) stopTimeout() time.Duration {
+	if s.config().Factorio.StopTimeout <= 0 {
+		return defaultStopTimeout
+	}
+	return time.Duration(s.config().Factorio.StopTimeout) * time.Second
+}
+
 // Status returns the download availability, current running state and version of the Factorio server.
 func (s *ServerManager) Status() ServerStatus {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	crashes := make([]CrashEvent, len(s.crashes))
+	copy(crashes, s.crashes)
+	transitions := make([]StateTransition, len(s.stateHistory))
+	copy(transitions, s.stateHistory)
+	stateSince := time.Time{}
+	if len(transitions) > 0 {
+		stateSince = transitions[len(transitions)-1].Time
+	}
 
-	return ServerStatus{
-		CanDownload:  s.cfg.Factorio.Username != "" && s.cfg.Factorio.Token != "",
+	status := ServerStatus{
+		CanDownload:  s.config().Factorio.Username != "" && s.config().Factorio.Token != "",
+		Crashes:      crashes,
 		IsConfigured: s.isConfigured(),
-		Running:      s.running,
-		Version:      s.GetVersion(),
+		LastExit:     s.lastExit,
+		RCon:         s.rcon.Health(),
+		Logs: LogStats{
+			Dropped:     s.logDropped,
+			LastSeq:     s.logSeq,
+			Subscribers: len(s.logSubscribers),
+		},
+		NextRestart: s.nextRestart,
+		Restarts:    s.restarts,
+		Running:     s.running,
+		State:       s.state,
+		StateSince:  stateSince,
+		Transitions: transitions,
+		Version:     s.Version,
+	}
+	s.mu.Unlock()
+
+	// A stopped server's version is read from the binary, which is not run under
+	// s.mu so other manager calls are not held up by it.
+	if !status.Running {
+		status.Version = s.binaryVersion()
 	}
+	return status
 }
 
 func (s *ServerManager) InitialiseConfiguration(overwrite bool) {
 	var configFiles = getConfigFiles()
 	for _, f := range configFiles {
 		installPath := fmt.Sprintf("%s/%s/%s/factorio/data",
-			s.cfg.Factorio.ServerVersions, s.cfg.Factorio.SelectedBranch, s.cfg.Factorio.SelectedVersion)
+			s.config().Factorio.ServerVersions, s.config().Factorio.SelectedBranch, s.config().Factorio.SelectedVersion)
 
 		src := fmt.Sprintf("%s/%s.example.json", installPath, f)
-		dst := fmt.Sprintf("%s/%s.json", s.cfg.Factorio.ConfigDir, f)
+		dst := fmt.Sprintf("%s/%s.json", s.config().Factorio.ConfigDir, f)
 		if !helpers.FileExists(src) {
 			log.Printf("%s not found, skipping\n", src)
 			continue
@@ -177,31 +439,41 @@ func (s *ServerManager) InitialiseConfiguration(overwrite bool) {
 // createFilesAndDirectories ensures that all required Factorio-related
 // directories and configuration files exist.
 func (s *ServerManager) createFilesAndDirectories() {
-	helpers.CreateDirectoryIfMissing(s.cfg.Factorio.ConfigDir)
-	helpers.CreateDirectoryIfMissing(s.cfg.Factorio.Downloads)
-	helpers.CreateDirectoryIfMissing(s.cfg.Factorio.LogsDir)
-	helpers.CreateDirectoryIfMissing(s.cfg.Factorio.ModsDir)
-	helpers.CreateDirectoryIfMissing(s.cfg.Factorio.SavesDir)
-	helpers.CreateDirectoryIfMissing(s.cfg.Factorio.ServerVersions)
-
-	helpers.CreateFileIfMissing(fmt.Sprintf("%s/mod-list.json", s.cfg.Factorio.ModsDir), "{}")
-	helpers.CreateFileIfMissing(s.cfg.Factorio.Files.AdminList, "[]")
-	helpers.CreateFileIfMissing(s.cfg.Factorio.Files.BanList, "[]")
-	helpers.CreateFileIfMissing(s.cfg.Factorio.Files.WhiteList, "[]")
+	helpers.CreateDirectoryIfMissing(s.config().Factorio.ConfigDir)
+	helpers.CreateDirectoryIfMissing(s.config().Factorio.Downloads)
+	helpers.CreateDirectoryIfMissing(s.config().Factorio.LogsDir)
+	helpers.CreateDirectoryIfMissing(s.config().Factorio.ModsDir)
+	helpers.CreateDirectoryIfMissing(s.config().Factorio.SavesDir)
+	helpers.CreateDirectoryIfMissing(s.config().Factorio.ServerVersions)
+	helpers.CreateDirectoryIfMissing(s.config().Server.DataDir)
+
+	helpers.CreateFileIfMissing(fmt.Sprintf("%s/mod-list.json", s.config().Factorio.ModsDir), "{}")
+	helpers.CreateFileIfMissing(s.config().Factorio.Files.AdminList, "[]")
+	helpers.CreateFileIfMissing(s.config().Factorio.Files.BanList, "[]")
+	helpers.CreateFileIfMissing(s.config().Factorio.Files.WhiteList, "[]")
 }
 
-// broadcastLogLine sends a log line to all subscribed log channels
-// and writes the line to standard output.
-func (s *ServerManager) broadcastLogLine(line string) {
+// broadcastLogLine records a log line in the history, publishes any event parsed
+// from it, sends it to all subscribed log channels and writes it to standard output.
+func (s *ServerManager) broadcastLogLine(text string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
+	s.logSeq++
+	line := LogLine{Seq: s.logSeq, Text: text, Time: time.Now()}
+	s.logHistory.add(line)
+	if event, ok := logparser.Parse(text, line.Time); ok {
+		event.Seq = line.Seq
+		s.publishEvent(event)
+	}
 	for _, ch := range s.logSubscribers {
 		select {
 		case ch <- line:
 		default:
+			s.logDropped++
 		}
 	}
-	fmt.Fprintln(os.Stdout, line)
+	fmt.Fprintln(os.Stdout, text)
 }
 
 // buildArgs assembles the command-line arguments used to launch the Factorio server
@@ -209,41 +481,42 @@ func (s *ServerManager) broadcastLogLine(line string) {
 func (s *ServerManager) buildArgs() []string {
 	args := []string{
 		"--server-settings",
-		s.cfg.Factorio.Files.ServerSettings,
+		s.config().Factorio.Files.ServerSettings,
 		"--server-adminlist",
-		s.cfg.Factorio.Files.AdminList,
+		s.config().Factorio.Files.AdminList,
 		"--server-banlist",
-		s.cfg.Factorio.Files.BanList,
+		s.config().Factorio.Files.BanList,
 		"--server-whitelist",
-		s.cfg.Factorio.Files.WhiteList,
+		s.config().Factorio.Files.WhiteList,
 		"--use-server-whitelist",
 		"--mod-directory",
-		s.cfg.Factorio.ModsDir,
+		s.config().Factorio.ModsDir,
 		"--server-id",
-		s.cfg.Factorio.Files.ServerId,
+		s.config().Factorio.Files.ServerId,
 	}
 
-	if s.cfg.Factorio.Bind != "" {
-		args = append(args, "--bind", s.cfg.Factorio.Bind)
+	if s.config().Factorio.Bind != "" {
+		args = append(args, "--bind", s.config().Factorio.Bind)
 	}
 
-	if s.cfg.Factorio.LogsDir != "" {
-		args = append(args, "--console-log", fmt.Sprintf("%s/%s.log", s.cfg.Factorio.LogsDir, time.Now().Format("200601021504")))
+	if s.config().Factorio.LogsDir != "" {
+		s.consoleLog = fmt.Sprintf("%s.log", time.Now().Format("200601021504"))
+		args = append(args, "--console-log", fmt.Sprintf("%s/%s", s.config().Factorio.LogsDir, s.consoleLog))
 	}
 
-	if s.cfg.Factorio.Save == "" {
+	if s.config().Factorio.Save == "" {
 		args = append(args, "--start-server-load-latest")
 	} else {
-		args = append(args, "--start-server", fmt.Sprintf("%s/%s", s.cfg.Factorio.SavesDir, s.cfg.Factorio.Save))
+		args = append(args, "--start-server", fmt.Sprintf("%s/%s", s.config().Factorio.SavesDir, s.config().Factorio.Save))
 	}
 
-	if s.cfg.RCon.Enabled {
-		if s.cfg.RCon.Bind != "" {
-			args = append(args, "--rcon-bind", s.cfg.RCon.Bind)
+	if s.config().RCon.Enabled {
+		if s.config().RCon.Bind != "" {
+			args = append(args, "--rcon-bind", s.config().RCon.Bind)
 		}
-		if s.cfg.RCon.Password != "" {
+		if s.config().RCon.Password != "" {
 			args = append(args,
-				"--rcon-password", s.cfg.RCon.Password,
+				"--rcon-password", s.config().RCon.Password,
 			)
 		}
 	}
@@ -251,10 +524,16 @@ func (s *ServerManager) buildArgs() []string {
 	return args
 }
 
+// binaryPath returns the path of the selected Factorio binary.
+func (s *ServerManager) binaryPath() string {
+	return fmt.Sprintf("%s/%s/%s/factorio/bin/x64/factorio",
+		s.config().Factorio.ServerVersions, s.config().Factorio.SelectedBranch, s.config().Factorio.SelectedVersion)
+}
+
 func (s *ServerManager) isConfigured() bool {
 	var configFiles = getConfigFiles()
 	for _, f := range configFiles {
-		var path = fmt.Sprintf("%s/%s.json", s.cfg.Factorio.ConfigDir, f)
+		var path = fmt.Sprintf("%s/%s.json", s.config().Factorio.ConfigDir, f)
 		if !helpers.FileExists(path) {
 			return false
 		}
@@ -278,19 +557,36 @@ func streamOutput(pipe io.ReadCloser, s *ServerManager) {
 	}
 }
 
-// GetVersion retrieves the full version string of the selected Factorio binary.
-// It executes the binary with --version and extracts the version from its output.
+// exitStatusOf extracts the exit code and terminating signal from a finished process.
+func exitStatusOf(state *os.ProcessState) ExitStatus {
+	exit := ExitStatus{Code: -1, Time: time.Now()}
+	if state == nil {
+		return exit
+	}
+	exit.Code = state.ExitCode()
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		exit.Signal = ws.Signal().String()
+	}
+	return exit
+}
+
+// GetVersion retrieves the full version string of the selected Factorio binary,
+// or of the running server.
 func (s *ServerManager) GetVersion() ServerVersion {
 	if s.running {
 		return s.Version
 	}
+	return s.binaryVersion()
+}
 
-	if s.cfg.Factorio.SelectedBranch == "" || s.cfg.Factorio.SelectedVersion == "" {
+// binaryVersion executes the selected Factorio binary with --version and extracts
+// the version from its output.
+func (s *ServerManager) binaryVersion() ServerVersion {
+	if s.config().Factorio.SelectedBranch == "" || s.config().Factorio.SelectedVersion == "" {
 		return ServerVersion{}
 	}
 
-	binaryPath := fmt.Sprintf("%s/%s/%s/factorio/bin/x64/factorio",
-		s.cfg.Factorio.ServerVersions, s.cfg.Factorio.SelectedBranch, s.cfg.Factorio.SelectedVersion)
+	binaryPath := s.binaryPath()
 	if !helpers.FileExists(binaryPath) {
 		return ServerVersion{}
 	}
@@ -308,8 +604,8 @@ func (s *ServerManager) GetVersion() ServerVersion {
 			full := strings.TrimPrefix(line, "Version: ")
 			return ServerVersion{
 				Full:    full,
-				Branch:  s.cfg.Factorio.SelectedBranch,
-				Version: s.cfg.Factorio.SelectedVersion,
+				Branch:  s.config().Factorio.SelectedBranch,
+				Version: s.config().Factorio.SelectedVersion,
 			}
 		}
 	}
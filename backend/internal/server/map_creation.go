@@ -0,0 +1,196 @@
+package server
+
+// Creation of new maps by running the selected Factorio binary with --create,
+// reporting its progress to subscribers.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/snarf-dev/fsm/v2/internal/factorio"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+// Stages reported while a map is created.
+const (
+	MapStageDone       = "done"
+	MapStageFailed     = "failed"
+	MapStageGenerating = "generating"
+	MapStageSaving     = "saving"
+	MapStageStarting   = "starting"
+)
+
+// saveName restricts new save names to characters that are safe in file names.
+var saveName = regexp.MustCompile(`^[A-Za-z0-9 _.-]{1,100}$`)
+
+var (
+	errInvalidSaveName = errors.New("save names may only contain letters, digits, spaces, ., - and _")
+	errSaveExists      = errors.New("a save with this name already exists")
+)
+
+// MapOptions describes a map to create.
+type MapOptions struct {
+	Name   string  `json:"name"`   // Save file name, .zip is appended when missing
+	Preset string  `json:"preset"` // Map-gen preset to use instead of map-gen-settings.json
+	Seed   *uint32 `json:"seed"`   // Map seed, random when nil
+}
+
+// MapProgress is an update on a map being created.
+type MapProgress struct {
+	Error string `json:"error,omitempty"`
+	Line  string `json:"line,omitempty"`
+	Save  string `json:"save"`
+	Stage string `json:"stage"`
+}
+
+// CreateMap creates a new save in the saves directory with the map-gen settings
+// of the chosen preset or the config directory and map-settings.json. The server
+// is held in the updating state while the binary runs in the background;
+// progress is sent to SubscribeToMapCreation subscribers. It returns the name of
+// the save being created.
+func (s *ServerManager) CreateMap(opts MapOptions) (string, error) {
+	name := opts.Name
+	if !strings.HasSuffix(name, ".zip") {
+		name += ".zip"
+	}
+	if !saveName.MatchString(name) {
+		return "", errInvalidSaveName
+	}
+	savePath := filepath.Join(s.config().Factorio.SavesDir, name)
+
+	mapGenSettings := s.config().Factorio.Files.MapGenSettings
+	if opts.Preset != "" {
+		path, err := factorio.MapPresetPath(s.config(), opts.Preset)
+		if err != nil {
+			return "", err
+		}
+		mapGenSettings = path
+	}
+
+	binaryPath := s.binaryPath()
+	if !helpers.FileExists(binaryPath) {
+		return "", fmt.Errorf("%s does not exist", binaryPath)
+	}
+
+	args := []string{
+		"--create", savePath,
+		"--map-gen-settings", mapGenSettings,
+		"--map-settings", s.config().Factorio.Files.MapSettings,
+		"--mod-directory", s.config().Factorio.ModsDir,
+	}
+	if opts.Seed != nil {
+		args = append(args, "--map-gen-seed", strconv.FormatUint(uint64(*opts.Seed), 10))
+	}
+
+	if err := s.BeginUpdate("create map"); err != nil {
+		return "", err
+	}
+	// Check for the save only once the update has begun, so a concurrent
+	// create of the same name cannot pass the check as well. The save did not
+	// exist before this run, so removing it on failure only removes ours.
+	if helpers.FileExists(savePath) {
+		s.EndUpdate()
+		return "", errSaveExists
+	}
+
+	cmd := exec.Command(binaryPath, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		s.EndUpdate()
+		return "", err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		s.EndUpdate()
+		return "", err
+	}
+	log.Printf("Creating map %s\n", savePath)
+	s.broadcastMapProgress(MapProgress{Save: name, Stage: MapStageStarting})
+
+	go func() {
+		streamMapCreation(stdout, func(progress MapProgress) {
+			progress.Save = name
+			s.broadcastMapProgress(progress)
+		})
+
+		err := cmd.Wait()
+		if err == nil && !helpers.FileExists(savePath) {
+			err = errors.New("factorio exited without writing the save")
+		}
+		if err != nil {
+			// Remove a partial save while still updating, so no other create
+			// of the same name can have started in the meantime.
+			os.Remove(savePath)
+		}
+		// Leave the updating state before reporting, so clients may start the
+		// server as soon as they see the final stage.
+		s.EndUpdate()
+		if err != nil {
+			log.Printf("Failed to create map %s: %v\n", savePath, err)
+			s.broadcastMapProgress(MapProgress{Error: err.Error(), Save: name, Stage: MapStageFailed})
+			return
+		}
+		log.Printf("Created map %s\n", savePath)
+		s.broadcastMapProgress(MapProgress{Save: name, Stage: MapStageDone})
+	}()
+	return name, nil
+}
+
+// streamMapCreation forwards each output line of the binary, tagged with the
+// stage it indicates.
+func streamMapCreation(pipe io.Reader, send func(MapProgress)) {
+	stage := MapStageStarting
+	scanner := bufio.NewScanner(pipe)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.Contains(line, "Creating new map"):
+			stage = MapStageGenerating
+		case strings.Contains(line, "Saving"):
+			stage = MapStageSaving
+		}
+		send(MapProgress{Line: line, Stage: stage})
+	}
+}
+
+// SubscribeToMapCreation returns a channel receiving map creation progress and a
+// function that removes the subscription.
+func (s *ServerManager) SubscribeToMapCreation() (<-chan MapProgress, func()) {
+	ch := make(chan MapProgress, 100)
+	s.mu.Lock()
+	s.mapSubscribers = append(s.mapSubscribers, ch)
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.mapSubscribers {
+			if sub == ch {
+				s.mapSubscribers = append(s.mapSubscribers[:i], s.mapSubscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// broadcastMapProgress sends progress to every subscriber, dropping it for
+// subscribers that are not keeping up.
+func (s *ServerManager) broadcastMapProgress(progress MapProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.mapSubscribers {
+		select {
+		case ch <- progress:
+		default:
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+// HTTP handler functions for creating new maps and managing the named
+// map-gen-settings presets used to create them.
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/factorio"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/validators"
+)
+
+// handleCreateMap starts creating a new save from the JSON body ("name" and
+// optional "preset" and "seed") and responds with 202 and the save name.
+// Presets stored before they were validated are checked again here. Progress is
+// streamed by handleMapCreationStream.
+func (s *RestServer) handleCreateMap(w http.ResponseWriter, r *http.Request) {
+	var opts MapOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if opts.Preset != "" {
+		settings, err := factorio.ReadMapPreset(s.config(), opts.Preset)
+		if err != nil {
+			renderMapPresetError(w, err)
+			return
+		}
+		if !s.validateSettings(w, settings, "map-gen-settings", validators.MapGenSettingsRules) {
+			return
+		}
+	}
+
+	name, err := s.manager.CreateMap(opts)
+	switch {
+	case errors.Is(err, errInvalidSaveName), errors.Is(err, factorio.ErrInvalidMapPresetName):
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, errSaveExists):
+		helpers.RenderErrorJSON(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, factorio.ErrMapPresetNotFound):
+		helpers.RenderErrorJSON(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		log.Printf("Failed to create map: %v\n", err)
+		renderManagerError(w, err, "Failed to create map")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"save": name})
+}
+
+// handleMapCreationStream upgrades the HTTP connection to a WebSocket and streams
+// the progress of map creation: the output of the Factorio binary, tagged with
+// its stage, followed by a "done" or "failed" update.
+func (s *RestServer) handleMapCreationStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Println("upgrade:", err)
+		return
+	}
+	defer conn.Close()
+
+	progressCh, unsubscribe := s.manager.SubscribeToMapCreation()
+	defer unsubscribe()
+
+	ctx := keepAlive(r.Context(), conn, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case progress := <-progressCh:
+			if err := writeJSON(conn, progress); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleListMapPresets returns the names of the stored map-gen presets.
+func (s *RestServer) handleListMapPresets(w http.ResponseWriter, r *http.Request) {
+	names, err := factorio.ListMapPresets(s.config())
+	if err != nil {
+		log.Printf("Failed to list map presets: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to list map presets")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(names)
+}
+
+// handleGetMapPreset returns the map-gen-settings of a preset.
+// Expects a `name` path parameter.
+func (s *RestServer) handleGetMapPreset(w http.ResponseWriter, r *http.Request) {
+	settings, err := factorio.ReadMapPreset(s.config(), mux.Vars(r)["name"])
+	if err != nil {
+		renderMapPresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// handlePutMapPreset creates or replaces a preset with the map-gen-settings in
+// the JSON body, validated like map-gen-settings.json. Expects a `name` path parameter.
+func (s *RestServer) handlePutMapPreset(w http.ResponseWriter, r *http.Request) {
+	var settings map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil || settings == nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if !s.validateSettings(w, settings, "map-gen-settings", validators.MapGenSettingsRules) {
+		return
+	}
+	if err := factorio.WriteMapPreset(s.config(), mux.Vars(r)["name"], settings); err != nil {
+		renderMapPresetError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteMapPreset removes a preset. Expects a `name` path parameter.
+func (s *RestServer) handleDeleteMapPreset(w http.ResponseWriter, r *http.Request) {
+	if err := factorio.DeleteMapPreset(s.config(), mux.Vars(r)["name"]); err != nil {
+		renderMapPresetError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renderMapPresetError maps a preset store error to a response.
+func renderMapPresetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, factorio.ErrInvalidMapPresetName):
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, factorio.ErrMapPresetNotFound):
+		helpers.RenderErrorJSON(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("Map preset operation failed: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to access map preset")
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+// HTTP handlers for reading and updating the Factorio map-gen-settings.json and
+// map-settings.json files, validated against schemas derived from the example
+// files of the selected Factorio version.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/validators"
+)
+
+// handleGetMapGenSettings responds with the contents of map-gen-settings.json.
+func (s *RestServer) handleGetMapGenSettings(w http.ResponseWriter, r *http.Request) {
+	renderSettingsFile(w, s.config().Factorio.Files.MapGenSettings)
+}
+
+// handleUpdateMapGenSettings validates and merges a JSON payload into map-gen-settings.json.
+func (s *RestServer) handleUpdateMapGenSettings(w http.ResponseWriter, r *http.Request) {
+	s.updateSettingsFile(w, r, s.config().Factorio.Files.MapGenSettings, "map-gen-settings", validators.MapGenSettingsRules)
+}
+
+// handleGetMapSettings responds with the contents of map-settings.json.
+func (s *RestServer) handleGetMapSettings(w http.ResponseWriter, r *http.Request) {
+	renderSettingsFile(w, s.config().Factorio.Files.MapSettings)
+}
+
+// handleUpdateMapSettings validates and merges a JSON payload into map-settings.json.
+func (s *RestServer) handleUpdateMapSettings(w http.ResponseWriter, r *http.Request) {
+	s.updateSettingsFile(w, r, s.config().Factorio.Files.MapSettings, "map-settings", validators.MapSettingsRules)
+}
+
+// renderSettingsFile writes the raw JSON of a settings file.
+func renderSettingsFile(w http.ResponseWriter, path string) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Settings file does not exist yet, select a Factorio version first")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v\n", path, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read settings")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// updateSettingsFile validates the JSON payload against the schema derived from
+// <name>.example.json of the selected version, then merges it into the file at
+// path. Nested objects are merged key by key, so a payload only needs the
+// settings being changed. Rejected fields are returned with a 400.
+func (s *RestServer) updateSettingsFile(w http.ResponseWriter, r *http.Request, path string, name string, rules validators.SchemaRules) {
+	var updated map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updated); err != nil || updated == nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if !s.validateSettings(w, updated, name, rules) {
+		return
+	}
+
+	path = filepath.Clean(path)
+	original := map[string]interface{}{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to read %s: %v\n", path, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read original settings")
+		return
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &original); err != nil {
+			helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to parse original settings")
+			return
+		}
+	}
+	mergeSettings(original, updated)
+
+	finalData, err := json.MarshalIndent(original, "", "  ")
+	if err != nil {
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to re-encode settings")
+		return
+	}
+	// Write through a temporary file, so a crash cannot leave a truncated file
+	// behind that the server then refuses to start with.
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, finalData, 0644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.Printf("Failed to write to %s: %v\n", path, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to write settings")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateSettings checks settings against the schema of the named settings file,
+// rendering the rejected fields with a 400 when they are invalid. It reports
+// whether the settings are valid.
+func (s *RestServer) validateSettings(w http.ResponseWriter, settings map[string]interface{}, name string, rules validators.SchemaRules) bool {
+	schema, err := s.settingsSchema(name, rules)
+	if err != nil {
+		log.Printf("Failed to load %s schema: %v\n", name, err)
+		helpers.RenderErrorJSON(w, http.StatusConflict, "Unable to validate settings, select an installed Factorio version first")
+		return false
+	}
+	if errs := schema.Validate(settings); len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":    http.StatusBadRequest,
+			"errors":  errs,
+			"message": "Invalid settings",
+		})
+		return false
+	}
+	return true
+}
+
+// settingsSchema derives the schema of a settings file from the example shipped
+// with the selected Factorio version.
+func (s *RestServer) settingsSchema(name string, rules validators.SchemaRules) (*validators.Schema, error) {
+	cfg := s.config().Factorio
+	if cfg.SelectedBranch == "" || cfg.SelectedVersion == "" {
+		return nil, fmt.Errorf("no Factorio version selected")
+	}
+	example := fmt.Sprintf("%s/%s/%s/factorio/data/%s.example.json",
+		cfg.ServerVersions, cfg.SelectedBranch, cfg.SelectedVersion, name)
+	data, err := os.ReadFile(example)
+	if err != nil {
+		return nil, err
+	}
+	return validators.SchemaFromExample(data, rules)
+}
+
+// mergeSettings copies updated into original, merging nested objects key by key.
+func mergeSettings(original, updated map[string]interface{}) {
+	for k, v := range updated {
+		if child, ok := v.(map[string]interface{}); ok {
+			if existing, ok := original[k].(map[string]interface{}); ok {
+				mergeSettings(existing, child)
+				continue
+			}
+		}
+		original[k] = v
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+// Checks, run before the Factorio server starts, that the mods used by the save
+// it will load are installed and enabled.
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/snarf-dev/fsm/v2/internal/mods"
+	"github.com/snarf-dev/fsm/v2/internal/savefile"
+)
+
+// ModMismatchError is returned by Start when the save uses mods that are missing
+// or disabled, since loading it would remove their content from the map.
+type ModMismatchError struct {
+	Diff *mods.Diff
+	Save string
+}
+
+func (e *ModMismatchError) Error() string {
+	return fmt.Sprintf("save %s uses %d missing and %d disabled mods", e.Save, len(e.Diff.Missing), len(e.Diff.Disabled))
+}
+
+// checkSaveMods compares the mods of the save the server will load with the
+// installed mods. Mismatches that the game migrates are only logged. A save
+// whose header cannot be read is not checked. It does not take s.mu.
+func (s *ServerManager) checkSaveMods() error {
+	path, err := activeSavePath(s.config().Factorio.SavesDir, s.config().Factorio.Save)
+	if err != nil {
+		return nil
+	}
+
+	diff, _, err := saveModDiff(s.config().Factorio.ModsDir, path)
+	if err != nil {
+		log.Printf("Skipping mod check of %s: %v\n", path, err)
+		return nil
+	}
+	if diff.Blocking() {
+		return &ModMismatchError{Diff: diff, Save: path}
+	}
+	if !diff.Compatible() {
+		log.Printf("Mods differ from those of %s: %d in other versions, %d extra\n", path, len(diff.VersionMismatch), len(diff.Extra))
+	}
+	return nil
+}
+
+// saveModDiff compares the mods recorded in the save at savePath with the mods
+// installed in modsDir. It also returns the decoded header, whose FromInitialMap
+// reports that the recorded mods are those the map was created with.
+func saveModDiff(modsDir string, savePath string) (*mods.Diff, *savefile.Info, error) {
+	info, err := savefile.Read(savePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	required := make([]mods.Mod, 0, len(info.Mods))
+	for _, mod := range info.Mods {
+		required = append(required, mods.Mod{Name: mod.Name, Version: mod.Version})
+	}
+	diff, err := mods.Compare(modsDir, required)
+	return diff, info, err
+}
@@ -20,7 +20,7 @@ import (
 
 // modsHandler returns the full contents of mod-list.json as a JSON response.
 func (s *RestServer) modsHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := os.ReadFile(fmt.Sprintf("%s/mod-list.json", s.fsmConfig.Factorio.ModsDir))
+	data, err := os.ReadFile(fmt.Sprintf("%s/mod-list.json", s.config().Factorio.ModsDir))
 	if err != nil {
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read mod list")
 		return
@@ -45,7 +45,7 @@ func (s *RestServer) toggleModHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := fmt.Sprintf("%s/mod-list.json", s.fsmConfig.Factorio.ModsDir)
+	path := fmt.Sprintf("%s/mod-list.json", s.config().Factorio.ModsDir)
 	err = mods.SetModEnabled(path, modName, enabled)
 	if err != nil {
 		log.Printf("Failed to update %s: %v", path, err)
@@ -69,7 +69,7 @@ func (s *RestServer) toggleModHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *RestServer) bookmarkedModsHandler(w http.ResponseWriter, r *http.Request) {
-	resp, err := http.Get(fmt.Sprintf("https://mods.factorio.com/api/bookmarks?username=%s&token=%s", s.fsmConfig.Factorio.Username, s.fsmConfig.Factorio.Token))
+	resp, err := http.Get(fmt.Sprintf("https://mods.factorio.com/api/bookmarks?username=%s&token=%s", s.config().Factorio.Username, s.config().Factorio.Token))
 	if err != nil {
 		log.Printf("Error talking to Factorio server: %v\n", err)
 		helpers.RenderErrorJSON(w, http.StatusBadGateway, "Failed to query Factorio Bookmarks API")
@@ -102,13 +102,13 @@ func (s *RestServer) bookmarkedModsHandler(w http.ResponseWriter, r *http.Reques
 		modsInfo = append(modsInfo, modDetails)
 	}
 
-	available, err := factorio.GetAvailableMods(s.fsmConfig)
+	available, err := factorio.GetAvailableMods(s.config())
 	if err != nil {
 		log.Printf("Failed to get available mods %v\n", err)
 		available = []map[string][]string{}
 	}
 
-	installed, err := factorio.GetInstalledMods(s.fsmConfig)
+	installed, err := factorio.GetInstalledMods(s.config())
 	if err != nil {
 		log.Printf("Failed to get installed mods %v\n", err)
 		installed = []map[string][]string{}
@@ -129,7 +129,7 @@ func (s *RestServer) handleDownloadMod(w http.ResponseWriter, r *http.Request) {
 	mod := vars["mod"]
 	version := vars["version"]
 
-	_, err := factorio.DownloadMod(s.fsmConfig, mod, version)
+	_, err := factorio.DownloadMod(s.config(), mod, version)
 	if err != nil {
 		log.Printf("Failed to download mod %s-%s: %v\n", mod, version, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to download mod")
@@ -143,7 +143,7 @@ func (s *RestServer) handleInstallMod(w http.ResponseWriter, r *http.Request) {
 	mod := vars["mod"]
 	version := vars["version"]
 
-	err := factorio.InstallMod(s.fsmConfig, mod, version)
+	err := factorio.InstallMod(s.config(), mod, version)
 	if err != nil {
 		log.Printf("Failed to install mod %s-%s: %v\n", mod, version, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to install mod")
@@ -157,7 +157,7 @@ func (s *RestServer) handleUninstallMod(w http.ResponseWriter, r *http.Request)
 	mod := vars["mod"]
 	version := vars["version"]
 
-	err := factorio.UninstallMod(s.fsmConfig, mod, version)
+	err := factorio.UninstallMod(s.config(), mod, version)
 	if err != nil {
 		log.Printf("Failed to uninstall mod %s-%s: %v\n", mod, version, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to uninstall mod")
@@ -171,7 +171,7 @@ func (s *RestServer) handleDeleteMod(w http.ResponseWriter, r *http.Request) {
 	mod := vars["mod"]
 	version := vars["version"]
 
-	err := factorio.DeleteMod(s.fsmConfig, mod, version)
+	err := factorio.DeleteMod(s.config(), mod, version)
 	if err != nil {
 		log.Printf("Failed to delete mod %s-%s: %v\n", mod, version, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to delete mod")
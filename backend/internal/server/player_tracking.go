@@ -0,0 +1,71 @@
+package server
+
+// The online player list, maintained by combining join and leave events from
+// the log stream with periodic RCON polling.
+
+import (
+	"log"
+	"time"
+
+	"github.com/snarf-dev/fsm/v2/internal/logparser"
+	"github.com/snarf-dev/fsm/v2/internal/players"
+)
+
+// playerPollInterval is how often the online player list is refreshed over RCON.
+const playerPollInterval = 30 * time.Second
+
+// Players returns the tracker holding online players and session history.
+func (s *ServerManager) Players() *players.Tracker {
+	return s.players
+}
+
+// trackPlayers feeds join and leave events into the player tracker, closes all
+// sessions when the server goes down and periodically reconciles the online list.
+func (s *ServerManager) trackPlayers() {
+	events, _ := s.SubscribeToEvents()
+	states, _ := s.SubscribeToState()
+	ticker := time.NewTicker(playerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case event := <-events:
+			switch event.Type {
+			case logparser.EventJoin:
+				err = s.players.Join(event.Player, event.Time)
+			case logparser.EventLeave:
+				err = s.players.Leave(event.Player, event.Time)
+			}
+		case transition := <-states:
+			switch transition.To {
+			case StateStopped, StateCrashed, StateStopping:
+				err = s.players.EndAll(transition.Time)
+			}
+		case <-ticker.C:
+			err = s.pollPlayers()
+		}
+		if err != nil {
+			log.Printf("Failed to update player history: %v\n", err)
+		}
+	}
+}
+
+// pollPlayers asks the server for the online player list over RCON and
+// reconciles it with the tracker.
+func (s *ServerManager) pollPlayers() error {
+	s.mu.Lock()
+	running := s.state == StateRunning
+	s.mu.Unlock()
+
+	if !running || !s.config().RCon.Enabled {
+		return nil
+	}
+
+	output, err := s.rcon.Execute("/players online")
+	if err != nil {
+		log.Printf("Failed to poll online players: %v\n", err)
+		return nil
+	}
+	return s.players.Sync(players.ParseOnline(output), time.Now())
+}
@@ -0,0 +1,46 @@
+package server
+
+// HTTP handlers for querying online players and the recorded session history
+// of each player.
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+// handleListPlayers returns the online players and a summary of every known player.
+// An optional "at" query parameter (RFC 3339) lists the players online at that time.
+func (s *RestServer) handleListPlayers(w http.ResponseWriter, r *http.Request) {
+	tracker := s.manager.Players()
+	response := map[string]interface{}{
+		"online":  tracker.Online(),
+		"players": tracker.List(),
+	}
+
+	if at := r.URL.Query().Get("at"); at != "" {
+		t, err := parseQueryTime(at)
+		if err != nil {
+			helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid at time")
+			return
+		}
+		response["online_at"] = tracker.OnlineAt(t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetPlayer returns the session history of a single player.
+// The player name is passed as a URL path variable.
+func (s *RestServer) handleGetPlayer(w http.ResponseWriter, r *http.Request) {
+	player, ok := s.manager.Players().Get(mux.Vars(r)["name"])
+	if !ok {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Player not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(player)
+}
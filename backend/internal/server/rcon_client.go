@@ -0,0 +1,234 @@
+package server
+
+// A long-lived RCON client owned by the ServerManager. It connects once the
+// Factorio server is running, reconnects with backoff when the connection drops
+// and serialises commands from every part of FSM.
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorcon/rcon"
+	"github.com/snarf-dev/fsm/v2/internal/config"
+)
+
+const (
+	rconCommandTimeout = 10 * time.Second
+	rconDialTimeout    = 5 * time.Second
+	rconMinBackoff     = time.Second
+	rconMaxBackoff     = 30 * time.Second
+)
+
+// RConHealth reports the state of the shared RCON connection for /status.
+type RConHealth struct {
+	Connected   bool       `json:"connected"`
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	Enabled     bool       `json:"enabled"`
+	Failures    int        `json:"failures"`
+	LastError   string     `json:"last_error,omitempty"`
+	Reconnects  int        `json:"reconnects"`
+}
+
+// RConClient owns a single RCON connection shared by all FSM features.
+type RConClient struct {
+	mu       sync.Mutex // serialises commands and guards conn
+	conn     *rcon.Conn
+	settings func() config.RConConfig
+
+	stateMu sync.Mutex // guards wanted and health
+	wanted  bool
+	health  RConHealth
+	wake    chan struct{}
+}
+
+// RCon returns the shared RCON client used for all commands sent to the server.
+func (s *ServerManager) RCon() *RConClient {
+	return s.rcon
+}
+
+// newRConClient creates a client reading its address and password from settings
+// and starts the goroutine maintaining the connection.
+func newRConClient(settings func() config.RConConfig) *RConClient {
+	c := &RConClient{
+		settings: settings,
+		wake:     make(chan struct{}, 1),
+	}
+	go c.run()
+	return c
+}
+
+// Connect asks the client to establish and keep a connection open.
+func (c *RConClient) Connect() {
+	c.setWanted(true)
+}
+
+// Disconnect asks the client to close its connection and stop reconnecting.
+func (c *RConClient) Disconnect() {
+	c.setWanted(false)
+}
+
+// Health returns a snapshot of the connection state.
+func (c *RConClient) Health() RConHealth {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	health := c.health
+	health.Enabled = c.settings().Enabled
+	return health
+}
+
+// Execute sends a command using the default command timeout.
+func (c *RConClient) Execute(command string) (string, error) {
+	return c.ExecuteTimeout(command, rconCommandTimeout)
+}
+
+// ExecuteTimeout sends a command and waits at most timeout for the response.
+// Commands are executed one at a time. A failed or timed out command drops the
+// connection so that it is re-established in the background.
+func (c *RConClient) ExecuteTimeout(command string, timeout time.Duration) (string, error) {
+	if !c.settings().Enabled {
+		return "", fmt.Errorf("RCON is not enabled")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.connectLocked(); err != nil {
+		return "", err
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+	conn := c.conn
+	done := make(chan result, 1)
+	go func() {
+		output, err := conn.Execute(command)
+		done <- result{output, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			c.dropLocked(res.err)
+		}
+		return res.output, res.err
+	case <-time.After(timeout):
+		err := fmt.Errorf("RCON command timed out after %s", timeout)
+		c.dropLocked(err)
+		<-done
+		return "", err
+	}
+}
+
+// run keeps the connection in the desired state, retrying failed connection
+// attempts with exponential backoff.
+func (c *RConClient) run() {
+	backoff := rconMinBackoff
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-c.wake:
+		case <-retry:
+		}
+		retry = nil
+
+		c.stateMu.Lock()
+		wanted := c.wanted
+		c.stateMu.Unlock()
+
+		c.mu.Lock()
+		var err error
+		if wanted && c.settings().Enabled {
+			err = c.connectLocked()
+		} else {
+			c.closeLocked()
+		}
+		c.mu.Unlock()
+
+		if err != nil {
+			retry = time.After(backoff)
+			backoff = min(backoff*2, rconMaxBackoff)
+		} else {
+			backoff = rconMinBackoff
+		}
+	}
+}
+
+// setWanted records whether a connection should be held and wakes the run loop.
+func (c *RConClient) setWanted(wanted bool) {
+	c.stateMu.Lock()
+	c.wanted = wanted
+	c.stateMu.Unlock()
+	c.signal()
+}
+
+// signal wakes the run loop without blocking.
+func (c *RConClient) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// connectLocked dials the server unless a connection is already open.
+// The caller must hold c.mu.
+func (c *RConClient) connectLocked() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	settings := c.settings()
+	conn, err := rcon.Dial(settings.Bind, settings.Password,
+		rcon.SetDialTimeout(rconDialTimeout),
+		rcon.SetDeadline(0),
+	)
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if err != nil {
+		c.health.Failures++
+		c.health.LastError = err.Error()
+		return err
+	}
+
+	now := time.Now()
+	if c.health.ConnectedAt != nil {
+		c.health.Reconnects++
+	}
+	c.conn = conn
+	c.health.Connected = true
+	c.health.ConnectedAt = &now
+	c.health.LastError = ""
+	log.Printf("RCON connected to %s\n", settings.Bind)
+	return nil
+}
+
+// dropLocked closes a failed connection, records the error and schedules a
+// reconnect. The caller must hold c.mu.
+func (c *RConClient) dropLocked(cause error) {
+	log.Printf("RCON connection lost: %v\n", cause)
+	c.closeLocked()
+
+	c.stateMu.Lock()
+	c.health.Failures++
+	c.health.LastError = cause.Error()
+	c.stateMu.Unlock()
+	c.signal()
+}
+
+// closeLocked closes the connection if one is open. The caller must hold c.mu.
+func (c *RConClient) closeLocked() {
+	if c.conn == nil {
+		return
+	}
+	c.conn.Close()
+	c.conn = nil
+
+	c.stateMu.Lock()
+	c.health.Connected = false
+	c.stateMu.Unlock()
+}
@@ -1,18 +1,35 @@
 package server
 
-// Package server provides an HTTP interface for sending commands to the Factorio server via RCON.
+// Package server provides an HTTP and WebSocket interface for sending commands to the
+// Factorio server via RCON, along with the command history and audit trail.
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
-	"github.com/gorcon/rcon"
+	"github.com/snarf-dev/fsm/v2/internal/audit"
+	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/logparser"
 )
 
+// rconAction is the audit log action recorded for every RCON command.
+const rconAction = "rcon.command"
+
+// rconRequest is a command sent by a client over the RCON WebSocket.
+type rconRequest struct {
+	Command string `json:"command"`
+	ID      string `json:"id,omitempty"`
+}
+
 // rconHandler processes an HTTP request to send a command via RCON to the Factorio server.
 // It returns the command output as JSON or an error message if the command fails.
 func (s *RestServer) rconHandler(w http.ResponseWriter, r *http.Request) {
-	if !s.fsmConfig.RCon.Enabled {
+	if !s.config().RCon.Enabled {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
@@ -24,10 +41,15 @@ func (s *RestServer) rconHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	command := r.FormValue("command")
-	output, err := sendRCONCommand(s.fsmConfig.RCon.Bind, s.fsmConfig.RCon.Password, command)
+	output, err := s.executeRCON(r, command)
 	if err != nil {
+		status := http.StatusInternalServerError
+		var denied *auth.CommandDeniedError
+		if errors.As(err, &denied) {
+			status = http.StatusForbidden
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(status)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": err.Error(),
 		})
@@ -40,18 +62,177 @@ func (s *RestServer) rconHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// sendRCONCommand connects to the Factorio RCON interface at the given address using the provided
-// password and sends the specified command. It returns the server's response or an error.
-func sendRCONCommand(addr, password, command string) (string, error) {
-	client, err := rcon.Dial(addr, password)
+// handleRConStream upgrades the connection to a WebSocket console. Clients send
+// {"id", "command"} messages and receive responses interleaved with chat, join,
+// leave and command events from the server log.
+func (s *RestServer) handleRConStream(w http.ResponseWriter, r *http.Request) {
+	if !s.config().RCon.Enabled {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	conn, err := s.upgrade(w, r)
 	if err != nil {
-		return "", err
+		log.Println("upgrade:", err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := s.manager.SubscribeToEvents()
+	defer unsubscribe()
+
+	parent, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	replies := make(chan map[string]interface{}, 10)
+	reply := func(msg map[string]interface{}) {
+		select {
+		case replies <- msg:
+		case <-parent.Done():
+		}
 	}
-	defer client.Close()
+	ctx := keepAlive(parent, conn, func(msg []byte) {
+		var req rconRequest
+		if err := json.Unmarshal(msg, &req); err != nil || req.Command == "" {
+			reply(map[string]interface{}{"type": "error", "id": req.ID, "error": "Invalid command"})
+			return
+		}
+		output, err := s.executeRCON(r, req.Command)
+		if err != nil {
+			var denied *auth.CommandDeniedError
+			reply(map[string]interface{}{
+				"type":    "error",
+				"id":      req.ID,
+				"command": req.Command,
+				"denied":  errors.As(err, &denied),
+				"error":   err.Error(),
+			})
+			return
+		}
+		reply(map[string]interface{}{"type": "response", "id": req.ID, "command": req.Command, "output": output})
+	})
+
+	for {
+		var msg interface{}
+		select {
+		case <-ctx.Done():
+			return
+		case reply := <-replies:
+			msg = reply
+		case event := <-events:
+			switch event.Type {
+			case logparser.EventChat, logparser.EventCommand, logparser.EventJoin, logparser.EventLeave:
+				msg = map[string]interface{}{"type": "event", "event": event}
+			default:
+				continue
+			}
+		}
+		if err := writeJSON(conn, msg); err != nil {
+			return
+		}
+	}
+}
 
-	response, err := client.Execute(command)
+// handleRConHistory returns the RCON commands previously run by the requesting admin.
+// Accepts optional "q" (substring) and "limit" query parameters.
+func (s *RestServer) handleRConHistory(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.audit.Query(audit.Filter{
+		Action:   rconAction,
+		Contains: r.URL.Query().Get("q"),
+		Limit:    queryLimit(r, defaultSearchLimit, maxSearchLimit),
+		User:     currentUser(r),
+	})
 	if err != nil {
-		return "", err
+		log.Printf("Failed to read audit log: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read command history")
+		return
+	}
+
+	type historyEntry struct {
+		Command string    `json:"command"`
+		Outcome string    `json:"outcome"`
+		Time    time.Time `json:"time"`
+	}
+	history := make([]historyEntry, 0, len(entries))
+	for _, e := range entries {
+		history = append(history, historyEntry{
+			Command: e.Params["command"],
+			Outcome: e.Outcome,
+			Time:    e.Time,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// handleRConAudit returns the audit trail of RCON commands run by all admins.
+// Accepts optional "user", "q", "from", "to" (RFC 3339) and "limit" query parameters.
+func (s *RestServer) handleRConAudit(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := audit.Filter{
+		Action:   rconAction,
+		Contains: params.Get("q"),
+		Limit:    queryLimit(r, defaultSearchLimit, maxSearchLimit),
+		User:     params.Get("user"),
+	}
+
+	var err error
+	if filter.From, err = parseQueryTime(params.Get("from")); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid from time")
+		return
+	}
+	if filter.To, err = parseQueryTime(params.Get("to")); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid to time")
+		return
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		log.Printf("Failed to read audit log: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to read audit log")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// executeRCON checks a command against the RCON policy of the authenticated admin's
+// role, sends it if permitted and records it in the audit log along with its outcome.
+func (s *RestServer) executeRCON(r *http.Request, command string) (string, error) {
+	user := currentUser(r)
+	entry := audit.Entry{
+		Action:  rconAction,
+		IP:      s.clientIP(r),
+		Outcome: audit.OutcomeOK,
+		Params:  map[string]string{"command": command},
+		User:    user,
+	}
+	if key, ok := requestAPIKey(r); ok {
+		entry.Params["api_key"] = key.Name
+	}
+
+	var output string
+	role := s.roleOf(user)
+	err := s.commandPolicy(role).Check(role, command)
+	if err != nil {
+		entry.Outcome = audit.OutcomeDenied
+		entry.Detail = err.Error()
+		log.Printf("Denied RCON command from %s: %v\n", user, err)
+	} else if output, err = s.manager.RCon().Execute(command); err != nil {
+		entry.Outcome = audit.OutcomeError
+		entry.Detail = err.Error()
+	}
+	if err := s.audit.Record(entry); err != nil {
+		log.Printf("Failed to write audit log: %v\n", err)
+	}
+	return output, err
+}
+
+// commandPolicy returns the configured RCON policy for a role, or the built-in
+// policy when none is configured.
+func (s *RestServer) commandPolicy(role string) *auth.CommandPolicy {
+	if policy, ok := s.config().RConPolicies[role]; ok {
+		return auth.NewCommandPolicy(policy.Allow, policy.Deny)
 	}
-	return response, nil
+	return auth.DefaultCommandPolicy(role)
 }
@@ -1,31 +1,69 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"github.com/snarf-dev/fsm/v2/internal/audit"
 	"github.com/snarf-dev/fsm/v2/internal/auth"
 	"github.com/snarf-dev/fsm/v2/internal/config"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/savefile"
 )
 
+// httpShutdownTimeout bounds how long in-flight requests may take to finish on shutdown.
+const httpShutdownTimeout = 10 * time.Second
+
+// ErrUncleanShutdown is wrapped by errors returned from Start when shutdown did
+// not complete cleanly, e.g. the Factorio server had to be killed.
+var ErrUncleanShutdown = errors.New("unclean shutdown")
+
 type RestServer struct {
-	manager   *ServerManager
-	fsmConfig *config.FSMConfig
+	apiKeys        *auth.KeyStore
+	audit          *audit.Log
+	limiter        *auth.LoginLimiter
+	manager        *ServerManager
+	fsmConfig      atomic.Pointer[config.FSMConfig] // Replaced when the config file is reloaded
+	saveInfo       savefile.Cache
+	sessions       *auth.SessionStore
+	totp           totpState
+	trustedProxies atomic.Pointer[[]netip.Prefix]
 }
 
+// contextKey namespaces values stored in request contexts by this package.
+type contextKey string
+
+const (
+	apiKeyContextKey contextKey = "api_key" // API key used to authenticate, if any
+	tokenContextKey  contextKey = "token"   // Session token used to authenticate, if any
+	userContextKey   contextKey = "user"    // Authenticated FSM admin username
+)
+
 func CreateRestServer(cfg *config.FSMConfig) *RestServer {
 	server := RestServer{
-		manager:   CreateManager(cfg),
-		fsmConfig: cfg,
+		audit:   audit.New(filepath.Join(cfg.Server.DataDir, "audit.log"), auditRotation(cfg.Audit)),
+		limiter: auth.NewLoginLimiter(limiterPolicy(cfg.Login)),
+		manager: CreateManager(cfg),
 	}
+	server.fsmConfig.Store(cfg)
+	server.setTrustedProxies(cfg.Server.TrustedProxies)
 
 	if len(cfg.Admins) == 0 {
 		log.Println("No server admins, creating")
 		if password, hashedPassword, err := auth.GenerateRandomPassword(8); err != nil {
-			log.Panicf("unable to generate password: %w", err)
+			log.Panicf("unable to generate password: %v", err)
 			os.Exit(1)
 		} else {
 			cfg.Admins["admin"] = hashedPassword
@@ -34,6 +72,32 @@ func CreateRestServer(cfg *config.FSMConfig) *RestServer {
 		}
 	}
 
+	if cfg.Server.SessionSecret == "" {
+		secret, err := auth.GenerateSecret()
+		if err != nil {
+			log.Panicf("unable to generate session secret: %v", err)
+		}
+		cfg.Server.SessionSecret = secret
+		cfg.SaveToFile()
+	}
+
+	sessionsPath := filepath.Join(cfg.Server.DataDir, "sessions.json")
+	ttl := time.Duration(cfg.Server.SessionTTL) * time.Second
+	sessions, err := auth.OpenSessionStore(sessionsPath, cfg.Server.SessionSecret, ttl)
+	if err != nil {
+		log.Printf("failed to load sessions, starting with none: %v\n", err)
+		os.Remove(sessionsPath)
+		sessions, _ = auth.OpenSessionStore(sessionsPath, cfg.Server.SessionSecret, ttl)
+	}
+	server.sessions = sessions
+
+	keysPath := filepath.Join(cfg.Server.DataDir, "api-keys.json")
+	apiKeys, err := auth.OpenKeyStore(keysPath)
+	if err != nil {
+		log.Panicf("unable to load API keys from %s: %v", keysPath, err)
+	}
+	server.apiKeys = apiKeys
+
 	if cfg.Factorio.AutoStart {
 		err := server.manager.Start()
 		if err != nil {
@@ -44,8 +108,11 @@ func CreateRestServer(cfg *config.FSMConfig) *RestServer {
 	watchConfig(cfg.Path, func() {
 		err, newCfg := config.Load(&cfg.Path)
 		if err == nil {
-			server.manager.cfg = newCfg
-			server.fsmConfig = newCfg
+			server.manager.cfg.Store(newCfg)
+			server.fsmConfig.Store(newCfg)
+			server.audit.SetRotation(auditRotation(newCfg.Audit))
+			server.limiter.SetPolicy(limiterPolicy(newCfg.Login))
+			server.setTrustedProxies(newCfg.Server.TrustedProxies)
 			log.Println("Config reloaded")
 		}
 	})
@@ -53,82 +120,358 @@ func CreateRestServer(cfg *config.FSMConfig) *RestServer {
 	return &server
 }
 
-func (s *RestServer) Start() {
+// Start serves the API until ctx is cancelled, then shuts down gracefully: HTTP
+// connections are drained, WebSockets closed and the Factorio server stopped.
+// An error wrapping ErrUncleanShutdown is returned when shutdown did not complete
+// cleanly; any other error means the server could not be started or failed.
+func (s *RestServer) Start(ctx context.Context) error {
+	cfg := s.config().Server
+
+	// Requests derive their context from baseCtx, which is cancelled when the
+	// server shuts down so long-lived WebSocket handlers return.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	srv := &http.Server{
+		Addr:        cfg.Listen,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+		Handler:     s.router(),
+	}
+	srv.RegisterOnShutdown(cancelBase)
+
+	var redirect *http.Server
+	if cfg.TLSEnabled() {
+		certs, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			if err := s.manager.Shutdown(); err != nil {
+				log.Printf("Shutdown after failure incomplete: %v\n", err)
+			}
+			return fmt.Errorf("unable to enable TLS: %w", err)
+		}
+		srv.TLSConfig = tlsConfig(cfg, certs)
+		if cfg.RedirectHTTP != "" {
+			redirect = &http.Server{Addr: cfg.RedirectHTTP, Handler: redirectToHTTPS(cfg.Listen)}
+		}
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if srv.TLSConfig != nil {
+			log.Printf("Server manager running at %s (TLS %s+)\n", cfg.Listen, cfg.TLSMinVersion)
+			serveErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Server manager running at %s\n", cfg.Listen)
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+	if redirect != nil {
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS\n", cfg.RedirectHTTP)
+			serveErr <- redirect.ListenAndServe()
+		}()
+	}
+
+	var failure error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down")
+	case err := <-serveErr:
+		failure = fmt.Errorf("HTTP server failed: %w", err)
+		log.Printf("%v, shutting down\n", failure)
+	}
+
+	err := s.shutdown(srv, redirect)
+	if failure != nil {
+		if err != nil {
+			log.Printf("Shutdown after failure incomplete: %v\n", err)
+		}
+		return failure
+	}
+	return err
+}
+
+// shutdown drains the HTTP servers and stops the Factorio server.
+func (s *RestServer) shutdown(servers ...*http.Server) error {
+	var errs []error
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%w: HTTP connections not drained: %v", ErrUncleanShutdown, err))
+		}
+	}
+
+	if err := s.manager.Shutdown(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrUncleanShutdown, err))
+	}
+	return errors.Join(errs...)
+}
+
+// router registers every route and wraps them in the CORS handler.
+func (s *RestServer) router() http.Handler {
 	r := mux.NewRouter()
 
-	r.HandleFunc("/start", s.withAuth(s.startHandler)).Methods("GET")
-	r.HandleFunc("/stop", s.withAuth(s.stopHandler)).Methods("GET")
-	r.HandleFunc("/status", s.withAuth(s.statusHandler)).Methods("GET")
-	r.HandleFunc("/mods", s.withAuth(s.modsHandler)).Methods("GET")
-	r.HandleFunc("/mods/bookmarked", s.withAuth(s.bookmarkedModsHandler)).Methods("GET")
-	r.HandleFunc("/mods/download/{mod}/{version}", s.withAuth(s.handleDownloadMod)).Methods("GET")
-	r.HandleFunc("/mods/install/{mod}/{version}", s.withAuth(s.handleInstallMod)).Methods("PUT")
-	r.HandleFunc("/mods/uninstall/{mod}/{version}", s.withAuth(s.handleUninstallMod)).Methods("DELETE")
-	r.HandleFunc("/mods/{mod}/{version}", s.withAuth(s.handleDeleteMod)).Methods("DELETE")
-	r.HandleFunc("/toggle-mod", s.withAuth(s.toggleModHandler)).Methods("POST")
-	r.HandleFunc("/rcon", s.withAuth(s.rconHandler)).Methods("POST")
-	r.HandleFunc("/ws/logs", s.handleLogStream)
-	r.HandleFunc("/saves", s.withAuth(s.handleListSaves)).Methods("GET")
-	r.HandleFunc("/saves/{name}", s.withAuth(s.handleDownloadSave)).Methods("GET")
-	r.HandleFunc("/saves", s.withAuth(s.handleUploadSave)).Methods("POST")
-	r.HandleFunc("/saves/{name}", s.withAuth(s.handleDeleteSave)).Methods("DELETE")
-	r.HandleFunc("/settings", s.withAuth(s.handleGetSettings)).Methods("GET")
-	r.HandleFunc("/settings/save", s.withAuth(s.handleUpdateSave)).Methods("POST")
-
-	r.HandleFunc("/admins", s.withAuth(s.handleListAdmins)).Methods("GET")
-	r.HandleFunc("/admins", s.withAuth(s.handleAddAdmin)).Methods("POST")
-	r.HandleFunc("/admins/{user}", s.withAuth(s.handleUpdateAdmin)).Methods("POST")
-	r.HandleFunc("/admins/{user}", s.withAuth(s.handleDeleteAdmin)).Methods("DELETE")
-
-	r.HandleFunc("/factorio-admins", s.withAuth(s.handleListFactorioAdmins)).Methods("GET")
-	r.HandleFunc("/factorio-admins", s.withAuth(s.handleAddFactorioAdmin)).Methods("POST")
-	r.HandleFunc("/factorio-admins/{user}", s.withAuth(s.handleRemoveFactorioAdmin)).Methods("DELETE")
-
-	r.HandleFunc("/factorio-bans", s.withAuth(s.handleListFactorioBans)).Methods("GET")
-	r.HandleFunc("/factorio-bans", s.withAuth(s.handleAddFactorioBanUser)).Methods("POST")
-	r.HandleFunc("/factorio-bans/{user}", s.withAuth(s.handleRemoveFactorioBanUser)).Methods("DELETE")
-
-	r.HandleFunc("/factorio-whitelist", s.withAuth(s.handleListFactorioWhitelistUsers)).Methods("GET")
-	r.HandleFunc("/factorio-whitelist", s.withAuth(s.handleAddFactorioWhitelistUser)).Methods("POST")
-	r.HandleFunc("/factorio-whitelist/{user}", s.withAuth(s.handleRemoveFactorioWhitelistUser)).Methods("DELETE")
-
-	r.HandleFunc("/factorio-settings", s.withAuth(s.handleGetServerSettings)).Methods("GET")
-	r.HandleFunc("/factorio-settings", s.withAuth(s.handleUpdateServerSettings)).Methods("PUT")
-
-	r.HandleFunc("/factorio-versions", s.withAuth(s.handleListFactorioVersions)).Methods("GET")
-	r.HandleFunc("/factorio-versions/{branch}/{version}", s.withAuth(s.handleSelectFactorioVersion)).Methods("PUT")
-	r.HandleFunc("/factorio-versions/{branch}/{version}", s.withAuth(s.handleUninstallFactorioVersion)).Methods("DELETE")
-	r.HandleFunc("/factorio-versions/{branch}/{version}/download", s.withAuth(s.handleDownloadFactorioVersion)).Methods("GET")
-	r.HandleFunc("/ws/download/{branch}/{version}", s.handleDownloadProgressStream).Methods("GET")
-
-	r.HandleFunc("/factorio-user", s.withAuth(s.handleGetFactorioUserSettings)).Methods("GET")
-	r.HandleFunc("/factorio-user", s.withAuth(s.handleUpdateFactorioUserSettings)).Methods("POST")
+	r.HandleFunc("/login", s.handleLogin).Methods("POST")
+	r.HandleFunc("/logout", s.withAuth(auth.PermView, s.withAudit("auth.logout", s.handleLogout))).Methods("POST")
+	r.HandleFunc("/api-keys", s.withAuth(auth.PermView, s.handleListAPIKeys)).Methods("GET")
+	r.HandleFunc("/api-keys", s.withAuth(auth.PermView, s.withAudit("api_key.create", s.handleCreateAPIKey))).Methods("POST")
+	r.HandleFunc("/api-keys/{id}", s.withAuth(auth.PermView, s.withAudit("api_key.revoke", s.handleRevokeAPIKey))).Methods("DELETE")
+	r.HandleFunc("/lockouts", s.withAuth(auth.PermAdmins, s.handleListLockouts)).Methods("GET")
+	r.HandleFunc("/lockouts", s.withAuth(auth.PermAdmins, s.withAudit("lockout.clear", s.handleClearLockouts))).Methods("DELETE")
+	r.HandleFunc("/lockouts/{kind}/{value}", s.withAuth(auth.PermAdmins, s.withAudit("lockout.clear", s.handleClearLockout))).Methods("DELETE")
+	r.HandleFunc("/totp", s.withAuth(auth.PermView, s.handleTOTPStatus)).Methods("GET")
+	r.HandleFunc("/totp", s.withAuth(auth.PermView, s.withAudit("totp.disable", s.handleTOTPDisable))).Methods("DELETE")
+	r.HandleFunc("/totp/enroll", s.withAuth(auth.PermView, s.withAudit("totp.enroll", s.handleTOTPEnroll))).Methods("POST")
+	r.HandleFunc("/totp/verify", s.withAuth(auth.PermView, s.withAudit("totp.enable", s.handleTOTPVerify))).Methods("POST")
+	r.HandleFunc("/totp/recovery-codes", s.withAuth(auth.PermView, s.withAudit("totp.recovery_codes", s.handleTOTPRecoveryCodes))).Methods("POST")
+	r.HandleFunc("/start", s.withAuth(auth.PermServerControl, s.withAudit("server.start", s.startHandler))).Methods("GET")
+	r.HandleFunc("/stop", s.withAuth(auth.PermServerControl, s.withAudit("server.stop", s.stopHandler))).Methods("GET")
+	r.HandleFunc("/status", s.withAuth(auth.PermView, s.statusHandler)).Methods("GET")
+	r.HandleFunc("/mods", s.withAuth(auth.PermView, s.modsHandler)).Methods("GET")
+	r.HandleFunc("/mods/bookmarked", s.withAuth(auth.PermView, s.bookmarkedModsHandler)).Methods("GET")
+	r.HandleFunc("/mods/download/{mod}/{version}", s.withAuth(auth.PermMods, s.withAudit("mod.download", s.handleDownloadMod))).Methods("GET")
+	r.HandleFunc("/mods/install/{mod}/{version}", s.withAuth(auth.PermMods, s.withAudit("mod.install", s.handleInstallMod))).Methods("PUT")
+	r.HandleFunc("/mods/uninstall/{mod}/{version}", s.withAuth(auth.PermMods, s.withAudit("mod.uninstall", s.handleUninstallMod))).Methods("DELETE")
+	r.HandleFunc("/mods/{mod}/{version}", s.withAuth(auth.PermMods, s.withAudit("mod.delete", s.handleDeleteMod))).Methods("DELETE")
+	r.HandleFunc("/toggle-mod", s.withAuth(auth.PermMods, s.withAudit("mod.toggle", s.toggleModHandler))).Methods("POST")
+	r.HandleFunc("/rcon", s.withAuth(auth.PermRCon, s.rconHandler)).Methods("POST")
+	r.HandleFunc("/rcon/history", s.withAuth(auth.PermRCon, s.handleRConHistory)).Methods("GET")
+	r.HandleFunc("/audit", s.withAuth(auth.PermAdmins, s.handleAudit)).Methods("GET")
+	r.HandleFunc("/rcon/audit", s.withAuth(auth.PermAdmins, s.handleRConAudit)).Methods("GET")
+	r.HandleFunc("/ws/rcon", s.withAuth(auth.PermRCon, s.handleRConStream))
+	r.HandleFunc("/ws/logs", s.withAuth(auth.PermView, s.handleLogStream))
+	r.HandleFunc("/ws/status", s.withAuth(auth.PermView, s.handleStateStream))
+	r.HandleFunc("/ws/maps", s.withAuth(auth.PermSaves, s.handleMapCreationStream))
+	r.HandleFunc("/logs", s.withAuth(auth.PermView, s.handleListLogs)).Methods("GET")
+	r.HandleFunc("/logs/search", s.withAuth(auth.PermView, s.handleSearchLogs)).Methods("GET")
+	r.HandleFunc("/logs/{name}", s.withAuth(auth.PermView, s.handleDownloadLog)).Methods("GET")
+	r.HandleFunc("/logs/{name}/lines", s.withAuth(auth.PermView, s.handleReadLog)).Methods("GET")
+	r.HandleFunc("/players", s.withAuth(auth.PermView, s.handleListPlayers)).Methods("GET")
+	r.HandleFunc("/players/{name}", s.withAuth(auth.PermView, s.handleGetPlayer)).Methods("GET")
+	r.HandleFunc("/saves", s.withAuth(auth.PermView, s.handleListSaves)).Methods("GET")
+	r.HandleFunc("/saves/{name}", s.withAuth(auth.PermSaves, s.handleDownloadSave)).Methods("GET")
+	r.HandleFunc("/saves", s.withAuth(auth.PermSaves, s.withAudit("save.upload", s.handleUploadSave))).Methods("POST")
+	r.HandleFunc("/saves/{name}", s.withAuth(auth.PermSaves, s.withAudit("save.delete", s.handleDeleteSave))).Methods("DELETE")
+	r.HandleFunc("/saves/{name}/mods", s.withAuth(auth.PermView, s.handleSaveMods)).Methods("GET")
+	r.HandleFunc("/saves/{name}/sync-mods", s.withAuth(auth.PermMods, s.withAudit("mod.sync", s.handleSyncSaveMods))).Methods("POST")
+	r.HandleFunc("/maps", s.withAuth(auth.PermSaves, s.withAudit("map.create", s.handleCreateMap))).Methods("POST")
+	r.HandleFunc("/map-presets", s.withAuth(auth.PermView, s.handleListMapPresets)).Methods("GET")
+	r.HandleFunc("/map-presets/{name}", s.withAuth(auth.PermView, s.handleGetMapPreset)).Methods("GET")
+	r.HandleFunc("/map-presets/{name}", s.withAuth(auth.PermSettings, s.withAudit("map_preset.update", s.handlePutMapPreset))).Methods("PUT")
+	r.HandleFunc("/map-presets/{name}", s.withAuth(auth.PermSettings, s.withAudit("map_preset.delete", s.handleDeleteMapPreset))).Methods("DELETE")
+	r.HandleFunc("/backups", s.withAuth(auth.PermView, s.handleListBackups)).Methods("GET")
+	r.HandleFunc("/backups", s.withAuth(auth.PermSaves, s.withAudit("backup.create", s.handleCreateBackup))).Methods("POST")
+	r.HandleFunc("/backups/{name}", s.withAuth(auth.PermSaves, s.handleDownloadBackup)).Methods("GET")
+	r.HandleFunc("/backups/{name}/restore", s.withAuth(auth.PermSaves, s.withAudit("backup.restore", s.handleRestoreBackup))).Methods("POST")
+	r.HandleFunc("/settings", s.withAuth(auth.PermView, s.handleGetSettings)).Methods("GET")
+	r.HandleFunc("/settings/save", s.withAuth(auth.PermSaves, s.withAudit("save.select", s.handleUpdateSave))).Methods("POST")
+
+	r.HandleFunc("/admins", s.withAuth(auth.PermAdmins, s.handleListAdmins)).Methods("GET")
+	r.HandleFunc("/admins", s.withAuth(auth.PermAdmins, s.withAudit("admin.create", s.handleAddAdmin))).Methods("POST")
+	r.HandleFunc("/admins/{user}", s.withAuth(auth.PermAdmins, s.withAudit("admin.update", s.handleUpdateAdmin))).Methods("POST")
+	r.HandleFunc("/admins/{user}", s.withAuth(auth.PermAdmins, s.withAudit("admin.delete", s.handleDeleteAdmin))).Methods("DELETE")
+	r.HandleFunc("/admins/{user}/totp", s.withAuth(auth.PermAdmins, s.withAudit("admin.totp_reset", s.handleResetAdminTOTP))).Methods("DELETE")
+
+	r.HandleFunc("/factorio-admins", s.withAuth(auth.PermFactorioAdmins, s.handleListFactorioAdmins)).Methods("GET")
+	r.HandleFunc("/factorio-admins", s.withAuth(auth.PermFactorioAdmins, s.withAudit("factorio_admin.add", s.handleAddFactorioAdmin))).Methods("POST")
+	r.HandleFunc("/factorio-admins/{user}", s.withAuth(auth.PermFactorioAdmins, s.withAudit("factorio_admin.remove", s.handleRemoveFactorioAdmin))).Methods("DELETE")
+
+	r.HandleFunc("/factorio-bans", s.withAuth(auth.PermPlayers, s.handleListFactorioBans)).Methods("GET")
+	r.HandleFunc("/factorio-bans", s.withAuth(auth.PermPlayers, s.withAudit("ban.add", s.handleAddFactorioBanUser))).Methods("POST")
+	r.HandleFunc("/factorio-bans/{user}", s.withAuth(auth.PermPlayers, s.withAudit("ban.remove", s.handleRemoveFactorioBanUser))).Methods("DELETE")
+
+	r.HandleFunc("/factorio-whitelist", s.withAuth(auth.PermPlayers, s.handleListFactorioWhitelistUsers)).Methods("GET")
+	r.HandleFunc("/factorio-whitelist", s.withAuth(auth.PermPlayers, s.withAudit("whitelist.add", s.handleAddFactorioWhitelistUser))).Methods("POST")
+	r.HandleFunc("/factorio-whitelist/{user}", s.withAuth(auth.PermPlayers, s.withAudit("whitelist.remove", s.handleRemoveFactorioWhitelistUser))).Methods("DELETE")
+
+	r.HandleFunc("/factorio-settings", s.withAuth(auth.PermSettings, s.handleGetServerSettings)).Methods("GET")
+	r.HandleFunc("/factorio-settings", s.withAuth(auth.PermSettings, s.withAudit("settings.update", s.handleUpdateServerSettings))).Methods("PUT")
+	r.HandleFunc("/map-gen-settings", s.withAuth(auth.PermSettings, s.handleGetMapGenSettings)).Methods("GET")
+	r.HandleFunc("/map-gen-settings", s.withAuth(auth.PermSettings, s.withAudit("map_gen_settings.update", s.handleUpdateMapGenSettings))).Methods("PUT")
+	r.HandleFunc("/map-settings", s.withAuth(auth.PermSettings, s.handleGetMapSettings)).Methods("GET")
+	r.HandleFunc("/map-settings", s.withAuth(auth.PermSettings, s.withAudit("map_settings.update", s.handleUpdateMapSettings))).Methods("PUT")
+
+	r.HandleFunc("/factorio-versions", s.withAuth(auth.PermView, s.handleListFactorioVersions)).Methods("GET")
+	r.HandleFunc("/factorio-versions/{branch}/{version}", s.withAuth(auth.PermVersions, s.withAudit("version.select", s.handleSelectFactorioVersion))).Methods("PUT")
+	r.HandleFunc("/factorio-versions/{branch}/{version}", s.withAuth(auth.PermVersions, s.withAudit("version.uninstall", s.handleUninstallFactorioVersion))).Methods("DELETE")
+	r.HandleFunc("/factorio-versions/{branch}/{version}/download", s.withAuth(auth.PermVersions, s.withAudit("version.download", s.handleDownloadFactorioVersion))).Methods("GET")
+	r.HandleFunc("/ws/download/{branch}/{version}", s.withAuth(auth.PermVersions, s.handleDownloadProgressStream)).Methods("GET")
+
+	r.HandleFunc("/factorio-user", s.withAuth(auth.PermSettings, s.handleGetFactorioUserSettings)).Methods("GET")
+	r.HandleFunc("/factorio-user", s.withAuth(auth.PermSettings, s.withAudit("factorio_user.update", s.handleUpdateFactorioUserSettings))).Methods("POST")
 
 	fs := http.FileServer(http.Dir("./frontend/dist"))
 	r.PathPrefix("/").Handler(fs)
 
-	handler := cors.AllowAll().Handler(r)
-
-	log.Printf("Server manager running at %s\n", s.fsmConfig.Server.Listen)
-	http.ListenAndServe(s.fsmConfig.Server.Listen, handler)
+	return cors.New(cors.Options{
+		AllowCredentials: true,
+		AllowOriginFunc:  s.isAllowedOrigin,
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedMethods:   []string{http.MethodDelete, http.MethodGet, http.MethodPost, http.MethodPut},
+	}).Handler(r)
 }
 
-func (s *RestServer) withAuth(next http.HandlerFunc) http.HandlerFunc {
+// withAuth authenticates the request and rejects it with 403 unless the admin's
+// role grants perm.
+func (s *RestServer) withAuth(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			w.WriteHeader(http.StatusNoContent)
-			return
+		ctx := r.Context()
+		username, ok := "", false
+		var apiKey *auth.APIKey
+		if token := requestToken(r); strings.HasPrefix(token, auth.APIKeyPrefix) {
+			if key, err := s.apiKeys.Authenticate(token); err == nil {
+				username, ok, apiKey = key.CreatedBy, true, &key
+				ctx = context.WithValue(ctx, apiKeyContextKey, key)
+			}
+		} else if token != "" {
+			if session, err := s.sessions.Validate(token); err == nil {
+				username, ok = session.User, true
+				ctx = context.WithValue(ctx, tokenContextKey, token)
+			}
 		}
-
-		username, password, ok := r.BasicAuth()
-		if !ok || !auth.CheckPassword(s.fsmConfig.Admins[username], password) {
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
+		if !ok && s.config().Server.BasicAuth {
+			if user, password, hasBasic := r.BasicAuth(); hasBasic {
+				var wait time.Duration
+				if wait, ok = s.checkPassword(r, user, password); wait > 0 {
+					renderTooManyAttempts(w, wait)
+					return
+				}
+				if _, enrolled := s.config().AdminTOTPSecret(user); ok && enrolled {
+					log.Printf("Rejected Basic auth for %q, two-factor authentication is enabled\n", user)
+					s.limiter.Release(s.clientIP(r), user)
+					ok = false
+				} else if ok {
+					s.limiter.Succeed(s.clientIP(r), user)
+				}
+				username = user
+			}
+		}
+		if _, exists := s.config().AdminPasswordHash(username); !ok || !exists {
+			if s.config().Server.BasicAuth {
+				w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
 			http.Error(w, "", http.StatusUnauthorized)
 			return
 		}
-		next(w, r)
+		if !auth.HasPermission(s.roleOf(username), perm) || (apiKey != nil && !apiKey.HasScope(perm)) {
+			s.recordForbidden(r, username, perm)
+			helpers.RenderErrorJSON(w, http.StatusForbidden, "Permission denied")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(ctx, userContextKey, username)))
+	}
+}
+
+// roleOf returns the role of an FSM admin, falling back to the default role.
+func (s *RestServer) roleOf(username string) string {
+	role, _ := s.config().AdminRole(username)
+	return effectiveRole(role)
+}
+
+// effectiveRole returns role, or the default role when none is assigned.
+func effectiveRole(role string) string {
+	if role == "" {
+		return auth.DefaultRole
+	}
+	return role
+}
+
+// requestAPIKey returns the API key that authenticated the request, if any.
+func requestAPIKey(r *http.Request) (auth.APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey).(auth.APIKey)
+	return key, ok
+}
+
+// currentUser returns the FSM admin that authenticated the request.
+func currentUser(r *http.Request) string {
+	username, _ := r.Context().Value(userContextKey).(string)
+	return username
+}
+
+// clientIP returns the address of the client that sent the request. When the
+// request comes from a trusted proxy, X-Forwarded-For is walked from the right
+// and the first address not belonging to a trusted proxy is returned.
+func (s *RestServer) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	trusted := *s.trustedProxies.Load()
+	if !isTrustedProxy(host, trusted) {
+		return host
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(header, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				forwarded = append(forwarded, addr)
+			}
+		}
+	}
+	if len(forwarded) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+		return host
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if !isTrustedProxy(forwarded[i], trusted) {
+			return forwarded[i]
+		}
+	}
+	return forwarded[0]
+}
+
+// config returns the current FSM configuration, which is replaced as a whole
+// when the config file is reloaded.
+func (s *RestServer) config() *config.FSMConfig {
+	return s.fsmConfig.Load()
+}
+
+// setTrustedProxies replaces the networks whose X-Forwarded-For headers are trusted.
+func (s *RestServer) setTrustedProxies(entries []string) {
+	trusted := parseTrustedProxies(entries)
+	s.trustedProxies.Store(&trusted)
+}
+
+// parseTrustedProxies parses the trusted_proxies setting. Plain addresses are
+// treated as single-host networks; invalid entries are logged and skipped.
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		} else {
+			log.Printf("Ignoring invalid trusted proxy %q\n", entry)
+		}
+	}
+	return prefixes
+}
+
+// isTrustedProxy reports whether addr falls within one of the trusted networks.
+func isTrustedProxy(addr string, trusted []netip.Prefix) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
 	}
+	return false
 }
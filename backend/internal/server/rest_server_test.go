@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	s := &RestServer{}
+	s.setTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "bogus"})
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "198.51.100.7:1234", want: "198.51.100.7"},
+		{name: "no port", remoteAddr: "198.51.100.7", want: "198.51.100.7"},
+		{name: "untrusted peer spoofing forwarded for", remoteAddr: "198.51.100.7:1234", forwarded: []string{"203.0.113.9"}, want: "198.51.100.7"},
+		{name: "untrusted peer spoofing real ip", remoteAddr: "198.51.100.7:1234", realIP: "203.0.113.9", want: "198.51.100.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "trusted single host", remoteAddr: "192.0.2.1:1234", forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "trusted proxy without forwarded for", remoteAddr: "10.1.2.3:1234", want: "10.1.2.3"},
+		{name: "trusted proxy real ip", remoteAddr: "10.1.2.3:1234", realIP: "203.0.113.9", want: "203.0.113.9"},
+		{name: "forwarded for before real ip", remoteAddr: "10.1.2.3:1234", forwarded: []string{"203.0.113.9"}, realIP: "203.0.113.10", want: "203.0.113.9"},
+		{name: "client spoofed entry ignored", remoteAddr: "10.1.2.3:1234", forwarded: []string{"1.1.1.1, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:1234", forwarded: []string{"1.1.1.1, 203.0.113.9, 10.9.9.9"}, want: "203.0.113.9"},
+		{name: "multiple headers", remoteAddr: "10.1.2.3:1234", forwarded: []string{"1.1.1.1", "203.0.113.9"}, want: "203.0.113.9"},
+		{name: "all hops trusted", remoteAddr: "10.1.2.3:1234", forwarded: []string{"10.4.4.4, 10.5.5.5"}, want: "10.4.4.4"},
+		{name: "spoofed trusted address after client", remoteAddr: "10.1.2.3:1234", forwarded: []string{"203.0.113.9, 10.5.5.5"}, want: "203.0.113.9"},
+		{name: "unparsable entry is not skipped", remoteAddr: "10.1.2.3:1234", forwarded: []string{"203.0.113.9, garbage"}, want: "garbage"},
+		{name: "mapped ipv4 proxy", remoteAddr: "[::ffff:10.1.2.3]:1234", forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "ipv6 peer", remoteAddr: "[2001:db8::1]:1234", forwarded: []string{"203.0.113.9"}, want: "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/status", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := s.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	got := parseTrustedProxies([]string{" 10.1.2.3/8 ", "192.0.2.1", "", "2001:db8::/32", "not an address"})
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32"}
+	if len(got) != len(want) {
+		t.Fatalf("parseTrustedProxies() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
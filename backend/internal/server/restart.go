@@ -0,0 +1,136 @@
+package server
+
+// Crash detection and the automatic restart policy applied when the Factorio
+// process exits without being asked to.
+
+import (
+	"log"
+	"time"
+
+	"github.com/snarf-dev/fsm/v2/internal/config"
+)
+
+// maxCrashHistory bounds the number of crash events kept for /status.
+const maxCrashHistory = 20
+
+// CrashEvent records an unexpected exit of the Factorio process and the
+// restart decision taken for it.
+type CrashEvent struct {
+	Attempt   int        `json:"attempt"`
+	Exit      ExitStatus `json:"exit"`
+	RestartAt *time.Time `json:"restart_at,omitempty"`
+	Uptime    int64      `json:"uptime_ms"`
+}
+
+// handleUnexpectedExit records a crash and schedules a restart according to
+// the configured policy. The caller must hold s.mu.
+func (s *ServerManager) handleUnexpectedExit(exit ExitStatus) {
+	policy := s.config().Restart
+	uptime := exit.Time.Sub(s.startedAt)
+	if policy.ResetAfter > 0 && uptime >= time.Duration(policy.ResetAfter)*time.Second {
+		s.restartAttempts = 0
+	}
+
+	event := CrashEvent{
+		Attempt: s.restartAttempts,
+		Exit:    exit,
+		Uptime:  uptime.Milliseconds(),
+	}
+
+	if shouldRestart(policy.Policy, exit) {
+		if s.restartAttempts >= policy.MaxRetries {
+			log.Printf("Server crashed %d times in a row, giving up on automatic restarts\n", s.restartAttempts)
+		} else {
+			delay := restartBackoff(policy, s.restartAttempts)
+			restartAt := time.Now().Add(delay)
+			s.restartAttempts++
+			s.restartGen++
+			gen := s.restartGen
+			s.nextRestart = &restartAt
+			s.restartTimer = time.AfterFunc(delay, func() { s.restartAfterCrash(gen) })
+			event.RestartAt = &restartAt
+			log.Printf("Server exited unexpectedly, restarting in %s (attempt %d/%d)\n", delay, s.restartAttempts, policy.MaxRetries)
+		}
+	} else {
+		log.Println("Server exited unexpectedly")
+	}
+
+	s.crashes = append(s.crashes, event)
+	if len(s.crashes) > maxCrashHistory {
+		s.crashes = s.crashes[len(s.crashes)-maxCrashHistory:]
+	}
+}
+
+// restartAfterCrash is invoked by the restart timer of generation gen to bring
+// the server back up. The save is backed up without holding s.mu, so the restart
+// is checked again afterwards in case it was cancelled in the meantime.
+func (s *ServerManager) restartAfterCrash(gen int) {
+	if !s.restartPending(gen) {
+		return
+	}
+	s.backupBeforeStart()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.restartTimer == nil || s.restartGen != gen {
+		log.Println("Automatic restart was cancelled")
+		return
+	}
+	s.restartTimer = nil
+	s.nextRestart = nil
+	if s.running {
+		return
+	}
+
+	s.restarts++
+	if err := s.startLocked(false); err != nil {
+		log.Printf("Automatic restart failed: %v\n", err)
+	}
+}
+
+// restartPending reports whether the restart of generation gen is still
+// scheduled, i.e. it was neither cancelled nor replaced by a later one.
+func (s *ServerManager) restartPending(gen int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restartTimer != nil && s.restartGen == gen
+}
+
+// cancelRestart stops a pending automatic restart, returning true if one was
+// scheduled. The caller must hold s.mu.
+func (s *ServerManager) cancelRestart() bool {
+	if s.restartTimer == nil {
+		return false
+	}
+	s.restartTimer.Stop()
+	s.restartTimer = nil
+	s.nextRestart = nil
+	return true
+}
+
+// shouldRestart reports whether the policy calls for a restart after exit.
+func shouldRestart(policy string, exit ExitStatus) bool {
+	switch policy {
+	case config.RestartAlways:
+		return true
+	case config.RestartOnFailure:
+		return exit.Code != 0 || exit.Signal != ""
+	default:
+		return false
+	}
+}
+
+// restartBackoff returns the exponential delay before the given restart attempt,
+// bounded by the configured maximum.
+func restartBackoff(policy config.RestartConfig, attempt int) time.Duration {
+	delay := time.Duration(policy.BackoffMin) * time.Second
+	limit := time.Duration(policy.BackoffMax) * time.Second
+	for i := 0; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	return delay
+}
@@ -10,26 +10,34 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/savefile"
 )
 
 // handleListSaves returns a JSON list of all save files in the configured saves directory.
-// Each entry includes the name, size, and last modified time.
+// Each entry includes the name, size, and last modified time, and for zips the
+// decoded save header with a flag set when the save was made with a newer
+// Factorio version than the selected one. Headers are cached until the save changes.
 func (s *RestServer) handleListSaves(w http.ResponseWriter, r *http.Request) {
-	files, err := os.ReadDir(s.fsmConfig.Factorio.SavesDir)
+	files, err := os.ReadDir(s.config().Factorio.SavesDir)
 	if err != nil {
-		log.Printf("Failed to read %s: %v", s.fsmConfig.Factorio.SavesDir, err)
+		log.Printf("Failed to read %s: %v", s.config().Factorio.SavesDir, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to list saves")
 		return
 	}
 	type Save struct {
-		Name    string    `json:"name"`
-		Size    int64     `json:"size"`
-		ModTime time.Time `json:"modTime"`
+		Info            *savefile.Info `json:"info,omitempty"`
+		InfoError       string         `json:"info_error,omitempty"`
+		Name            string         `json:"name"`
+		NewerThanServer bool           `json:"newer_than_server"`
+		Size            int64          `json:"size"`
+		ModTime         time.Time      `json:"modTime"`
 	}
+	serverVersion, versionErr := savefile.ParseVersion(s.config().Factorio.SelectedVersion)
 	var saves []Save
 	for _, f := range files {
 		if f.IsDir() {
@@ -39,11 +47,21 @@ func (s *RestServer) handleListSaves(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			continue
 		}
-		saves = append(saves, Save{
+		save := Save{
 			Name:    f.Name(),
 			Size:    info.Size(),
 			ModTime: info.ModTime(),
-		})
+		}
+		if strings.HasSuffix(f.Name(), ".zip") {
+			header, err := s.saveInfo.Read(filepath.Join(s.config().Factorio.SavesDir, f.Name()))
+			if err != nil {
+				save.InfoError = err.Error()
+			} else {
+				save.Info = header
+				save.NewerThanServer = versionErr == nil && header.Version.Compare(serverVersion) > 0
+			}
+		}
+		saves = append(saves, save)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(saves)
@@ -53,7 +71,7 @@ func (s *RestServer) handleListSaves(w http.ResponseWriter, r *http.Request) {
 // The file name is passed as a URL path variable.
 func (s *RestServer) handleDownloadSave(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	filePath := filepath.Join(s.fsmConfig.Factorio.SavesDir, filepath.Clean(name))
+	filePath := filepath.Join(s.config().Factorio.SavesDir, filepath.Clean(name))
 	w.Header().Set("Content-Disposition", "attachment; filename="+name)
 	http.ServeFile(w, r, filePath)
 }
@@ -74,7 +92,7 @@ func (s *RestServer) handleUploadSave(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	destPath := filepath.Join(s.fsmConfig.Factorio.SavesDir, filepath.Base(header.Filename))
+	destPath := filepath.Join(s.config().Factorio.SavesDir, filepath.Base(header.Filename))
 	out, err := os.Create(destPath)
 	if err != nil {
 		log.Printf("Failed to write %s: %v", destPath, err)
@@ -97,7 +115,7 @@ func (s *RestServer) handleUploadSave(w http.ResponseWriter, r *http.Request) {
 // The file name is passed as a URL path variable.
 func (s *RestServer) handleDeleteSave(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	filePath := filepath.Join(s.fsmConfig.Factorio.SavesDir, filepath.Clean(name))
+	filePath := filepath.Join(s.config().Factorio.SavesDir, filepath.Clean(name))
 	err := os.Remove(filePath)
 	if err != nil {
 		log.Printf("Failed to delete %s: %v", filePath, err)
@@ -0,0 +1,98 @@
+package server
+
+// HTTP handler functions for comparing the mods of a save with the installed
+// mods and syncing the installed mods to a save.
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/factorio"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/mods"
+)
+
+// handleSaveMods returns the differences between the mods of a save and the
+// installed mods. The save name is passed as a URL path variable.
+func (s *RestServer) handleSaveMods(w http.ResponseWriter, r *http.Request) {
+	savePath, ok := s.savePath(w, r)
+	if !ok {
+		return
+	}
+
+	diff, _, err := saveModDiff(s.config().Factorio.ModsDir, savePath)
+	if err != nil {
+		log.Printf("Failed to compare mods of %s: %v\n", savePath, err)
+		helpers.RenderErrorJSON(w, http.StatusUnprocessableEntity, "Failed to read the mods of the save")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// handleSyncSaveMods downloads, installs, enables and disables mods so that the
+// installed mods match those of a save, and returns the remaining differences.
+// The server must be stopped. When the save only records the mods it was created
+// with, mods it does not list are left enabled, as it may use them since.
+func (s *RestServer) handleSyncSaveMods(w http.ResponseWriter, r *http.Request) {
+	savePath, ok := s.savePath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.manager.BeginUpdate("sync mods"); err != nil {
+		renderManagerError(w, err, "Failed to sync mods")
+		return
+	}
+	defer s.manager.EndUpdate()
+
+	diff, info, err := saveModDiff(s.config().Factorio.ModsDir, savePath)
+	if err != nil {
+		log.Printf("Failed to compare mods of %s: %v\n", savePath, err)
+		helpers.RenderErrorJSON(w, http.StatusUnprocessableEntity, "Failed to read the mods of the save")
+		return
+	}
+	disableExtra := !info.FromInitialMap()
+	if !disableExtra && len(diff.Extra) > 0 {
+		log.Printf("Mod list of %s is from map creation, leaving %d extra mods enabled\n", savePath, len(diff.Extra))
+	}
+	if err := factorio.SyncMods(s.config(), diff, disableExtra); err != nil {
+		log.Printf("Failed to sync mods to %s: %v\n", savePath, err)
+		helpers.RenderErrorJSON(w, http.StatusBadGateway, "Failed to sync some mods: "+err.Error())
+		return
+	}
+	log.Printf("Synced mods to %s\n", savePath)
+
+	if diff, _, err = saveModDiff(s.config().Factorio.ModsDir, savePath); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to compare mods")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// savePath resolves the "name" path variable to a file in the saves directory,
+// rendering an error when it does not name a save.
+func (s *RestServer) savePath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := mux.Vars(r)["name"]
+	if name != filepath.Base(name) || !helpers.FileExists(filepath.Join(s.config().Factorio.SavesDir, name)) {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Save not found")
+		return "", false
+	}
+	return filepath.Join(s.config().Factorio.SavesDir, name), true
+}
+
+// renderModMismatch rejects a start because the save uses missing or disabled
+// mods, including the differences so clients can offer to sync them.
+func renderModMismatch(w http.ResponseWriter, err *ModMismatchError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(struct {
+		Code    int        `json:"code"`
+		Message string     `json:"message"`
+		Mods    *mods.Diff `json:"mods"`
+	}{http.StatusConflict, err.Error(), err.Diff})
+}
@@ -1,25 +1,44 @@
 // Package server provides HTTP handler functions for managing FSM admin users,
-// including listing, adding, updating, and deleting admins.
+// including listing, adding, updating, and deleting admins and assigning their roles.
 package server
 
 import (
 	"encoding/json"
+	"errors"
 	"html"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/config"
 	"github.com/snarf-dev/fsm/v2/internal/helpers"
 	"github.com/snarf-dev/fsm/v2/internal/validators"
 )
 
+var (
+	errAdminExists   = errors.New("admin already exists")
+	errAdminNotFound = errors.New("admin not found")
+	errDeleteOwner   = errors.New("cannot delete the last owner")
+	errDemoteOwner   = errors.New("cannot demote the last owner")
+)
+
+// adminInfo describes an FSM admin in API responses.
+type adminInfo struct {
+	Role string `json:"role"`
+	TOTP bool   `json:"totp"` // Whether two-factor authentication is enabled
+}
+
 // handleAddAdmin creates a new admin user with a hashed password and saves it to the config.
-// Expects a JSON body with "username" and "password" fields.
+// Expects a JSON body with "username" and "password" fields and an optional "role",
+// which defaults to the viewer role so new admins start without write access.
+// Existing admins are changed with handleUpdateAdmin.
 func (s *RestServer) handleAddAdmin(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
 	var payload struct {
-		Username string `json:"username"`
 		Password string `json:"password"`
+		Role     string `json:"role"`
+		Username string `json:"username"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
@@ -31,23 +50,42 @@ func (s *RestServer) handleAddAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if payload.Role == "" {
+		payload.Role = auth.RoleViewer
+	}
+	if !auth.IsValidRole(payload.Role) {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(payload.Password)
 	if err != nil {
 		log.Printf("Failed to hash password: %v", err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to hash password")
 		return
 	}
-	s.fsmConfig.Admins[payload.Username] = hashedPassword
-	s.fsmConfig.SaveToFile()
-	w.WriteHeader(http.StatusNoContent)
+	err = cfg.UpdateAdmins(func() error {
+		if _, ok := cfg.Admins[payload.Username]; ok {
+			return errAdminExists
+		}
+		cfg.Admins[payload.Username] = hashedPassword
+		cfg.Roles[payload.Username] = payload.Role
+		return nil
+	})
+	if !renderAdminUpdateError(w, err) {
+		w.WriteHeader(http.StatusNoContent)
+	}
 }
 
-// handleUpdateAdmin updates the password for an existing admin user.
-// Expects a JSON body with a "password" field and the username in the route variable.
+// handleUpdateAdmin updates the password and/or role of an existing admin user.
+// Expects a JSON body with optional "password" and "role" fields and the username
+// in the route variable. The last owner cannot be demoted.
 func (s *RestServer) handleUpdateAdmin(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
 	user := mux.Vars(r)["user"]
 	var payload struct {
 		Password string `json:"password"`
+		Role     string `json:"role"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
@@ -59,20 +97,54 @@ func (s *RestServer) handleUpdateAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(payload.Password)
-	if err != nil {
-		log.Printf("Failed to hash password: %v", err)
-		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to hash password")
+	if payload.Password == "" && payload.Role == "" {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	if payload.Role != "" && !auth.IsValidRole(payload.Role) {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid role")
 		return
 	}
-	s.fsmConfig.Admins[user] = hashedPassword
-	s.fsmConfig.SaveToFile()
+
+	var hashedPassword string
+	if payload.Password != "" {
+		var err error
+		if hashedPassword, err = auth.HashPassword(payload.Password); err != nil {
+			log.Printf("Failed to hash password: %v", err)
+			helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to hash password")
+			return
+		}
+	}
+
+	err := cfg.UpdateAdmins(func() error {
+		if _, ok := cfg.Admins[user]; !ok {
+			return errAdminNotFound
+		}
+		if payload.Role != "" && payload.Role != auth.RoleOwner && isLastOwner(cfg, user) {
+			return errDemoteOwner
+		}
+		if hashedPassword != "" {
+			cfg.Admins[user] = hashedPassword
+		}
+		if payload.Role != "" {
+			cfg.Roles[user] = payload.Role
+		}
+		return nil
+	})
+	if renderAdminUpdateError(w, err) {
+		return
+	}
+	if hashedPassword != "" {
+		s.revokeSessions(user)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handleDeleteAdmin removes an admin user from the config unless the user is deleting themselves.
-// The username to delete is provided in the route variable.
+// handleDeleteAdmin removes an admin user from the config unless the user is deleting
+// themselves or is the last owner. The username to delete is provided in the route variable.
 func (s *RestServer) handleDeleteAdmin(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
 	user := mux.Vars(r)["user"]
 
 	if !validators.IsUsernameValid(user) {
@@ -80,22 +152,76 @@ func (s *RestServer) handleDeleteAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username, _, _ := r.BasicAuth()
-	if user == username {
+	if user == currentUser(r) {
 		helpers.RenderErrorJSON(w, http.StatusForbidden, "Cannot delete yourself")
 		return
 	}
-	delete(s.fsmConfig.Admins, user)
-	s.fsmConfig.SaveToFile()
+
+	err := cfg.UpdateAdmins(func() error {
+		if isLastOwner(cfg, user) {
+			return errDeleteOwner
+		}
+		delete(cfg.Admins, user)
+		delete(cfg.Roles, user)
+		delete(cfg.RecoveryCodes, user)
+		delete(cfg.TOTP, user)
+		return nil
+	})
+	if renderAdminUpdateError(w, err) {
+		return
+	}
+	s.revokeSessions(user)
+	if err := s.apiKeys.RevokeUser(user); err != nil {
+		log.Printf("Failed to revoke API keys of %s: %v\n", user, err)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handleListAdmins returns a list of all admin usernames with password fields redacted.
+// handleListAdmins returns all admin usernames and their roles. Password hashes are never included.
 func (s *RestServer) handleListAdmins(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
 	w.Header().Set("Content-Type", "application/json")
-	admins := make(map[string]string)
-	for k := range s.fsmConfig.Admins {
-		admins[html.EscapeString(k)] = ""
-	}
+	admins := make(map[string]adminInfo)
+	cfg.ViewAdmins(func() {
+		for k := range cfg.Admins {
+			_, totp := cfg.TOTP[k]
+			admins[html.EscapeString(k)] = adminInfo{Role: effectiveRole(cfg.Roles[k]), TOTP: totp}
+		}
+	})
 	json.NewEncoder(w).Encode(admins)
 }
+
+// isLastOwner reports whether user is the only admin holding the owner role.
+// The caller must hold the admins lock through UpdateAdmins or ViewAdmins.
+func isLastOwner(cfg *config.FSMConfig, user string) bool {
+	if _, ok := cfg.Admins[user]; !ok || effectiveRole(cfg.Roles[user]) != auth.RoleOwner {
+		return false
+	}
+	for name := range cfg.Admins {
+		if name != user && effectiveRole(cfg.Roles[name]) == auth.RoleOwner {
+			return false
+		}
+	}
+	return true
+}
+
+// renderAdminUpdateError renders the error of an UpdateAdmins call, reporting
+// whether there was one.
+func renderAdminUpdateError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errAdminExists):
+		helpers.RenderErrorJSON(w, http.StatusConflict, "Admin already exists")
+	case errors.Is(err, errAdminNotFound):
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Admin not found")
+	case errors.Is(err, errDeleteOwner):
+		helpers.RenderErrorJSON(w, http.StatusConflict, "Cannot delete the last owner")
+	case errors.Is(err, errDemoteOwner):
+		helpers.RenderErrorJSON(w, http.StatusConflict, "Cannot demote the last owner")
+	default:
+		log.Printf("Failed to save admins: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
+	}
+	return true
+}
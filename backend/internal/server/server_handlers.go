@@ -4,54 +4,135 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
-	"github.com/gorilla/websocket"
 	"github.com/snarf-dev/fsm/v2/internal/helpers"
+	"github.com/snarf-dev/fsm/v2/internal/logparser"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// return r.Header.Get("Origin") == "http://your-frontend-domain"
-		return true
-	},
-}
-
 // handleLogStream upgrades the HTTP connection to a WebSocket and streams log output
-// from the running Factorio server to the connected client in real-time.
+// from the running Factorio server to the connected client in real-time as JSON.
+// The "replay" query parameter requests the last N buffered lines and "since"
+// resumes after the given sequence number. With "format=events" only lines that
+// parse into structured events are sent, encoded as events.
 func (s *RestServer) handleLogStream(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	replay, _ := strconv.Atoi(r.URL.Query().Get("replay"))
+	since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	events := r.URL.Query().Get("format") == "events"
+
+	conn, err := s.upgrade(w, r)
 	if err != nil {
 		log.Println("upgrade:", err)
 		return
 	}
 	defer conn.Close()
 
-	logCh := s.manager.SubscribeToLogs()
-	for msg := range logCh {
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
-			break
+	backlog, logCh, unsubscribe := s.manager.SubscribeToLogs(since, replay)
+	defer unsubscribe()
+
+	send := func(line LogLine) error {
+		if !events {
+			return writeJSON(conn, line)
+		}
+		event, ok := logparser.Parse(line.Text, line.Time)
+		if !ok {
+			return nil
+		}
+		event.Seq = line.Seq
+		return writeJSON(conn, event)
+	}
+
+	ctx := keepAlive(r.Context(), conn, nil)
+	for _, line := range backlog {
+		if err := send(line); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-logCh:
+			if err := send(line); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleStateStream upgrades the HTTP connection to a WebSocket and streams server
+// state transitions, starting with the current state.
+func (s *RestServer) handleStateStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrade(w, r)
+	if err != nil {
+		log.Println("upgrade:", err)
+		return
+	}
+	defer conn.Close()
+
+	stateCh, unsubscribe := s.manager.SubscribeToState()
+	defer unsubscribe()
+
+	ctx := keepAlive(r.Context(), conn, nil)
+	status := s.manager.Status()
+	if err := writeJSON(conn, map[string]interface{}{
+		"type":  "state",
+		"state": status.State,
+		"since": status.StateSince,
+	}); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case transition := <-stateCh:
+			if err := writeJSON(conn, map[string]interface{}{
+				"type":       "transition",
+				"transition": transition,
+			}); err != nil {
+				return
+			}
 		}
 	}
 }
 
 // startHandler starts the Factorio server and responds with the updated status as JSON.
+// The mods of the save are checked first unless "force=true" is passed.
 func (s *RestServer) startHandler(w http.ResponseWriter, r *http.Request) {
-	if err := s.manager.Start(); err != nil {
-		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to start the server")
+	start := s.manager.Start
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); force {
+		start = s.manager.StartForced
+	}
+	if err := start(); err != nil {
+		var mismatch *ModMismatchError
+		if errors.As(err, &mismatch) {
+			renderModMismatch(w, mismatch)
+			return
+		}
+		renderManagerError(w, err, "Failed to start the server")
 		return
 	}
 	s.renderStatusJSON(w)
 }
 
-// stopHandler stops the Factorio server and responds with the updated status as JSON.
+// stopHandler stops the Factorio server and responds with the updated status
+// and the outcome of the shutdown as JSON.
 func (s *RestServer) stopHandler(w http.ResponseWriter, r *http.Request) {
-	if err := s.manager.Stop(); err != nil {
-		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to stop the server")
+	result, err := s.manager.Stop()
+	if err != nil {
+		renderManagerError(w, err, "Failed to stop the server")
 		return
 	}
-	s.renderStatusJSON(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ServerStatus
+		Shutdown StopResult `json:"shutdown"`
+	}{s.manager.Status(), result})
 }
 
 // statusHandler returns the current server status as JSON.
@@ -64,3 +145,14 @@ func (s *RestServer) renderStatusJSON(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s.manager.Status())
 }
+
+// renderManagerError writes a 409 for operations rejected by the server state
+// and a 500 with the given message for any other failure.
+func renderManagerError(w http.ResponseWriter, err error, message string) {
+	var stateErr *StateError
+	if errors.As(err, &stateErr) {
+		helpers.RenderErrorJSON(w, http.StatusConflict, stateErr.Error())
+		return
+	}
+	helpers.RenderErrorJSON(w, http.StatusInternalServerError, message)
+}
@@ -15,7 +15,7 @@ import (
 func (s *RestServer) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"save": s.fsmConfig.Factorio.Save,
+		"save": s.config().Factorio.Save,
 	})
 }
 
@@ -30,11 +30,11 @@ func (s *RestServer) handleUpdateSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.fsmConfig.Factorio.Save = payload.Save
+	s.config().Factorio.Save = payload.Save
 
-	err := s.fsmConfig.SaveToFile()
+	err := s.config().SaveToFile()
 	if err != nil {
-		log.Printf("failed to update %s, %v\n", s.fsmConfig.Path, err)
+		log.Printf("failed to update %s, %v\n", s.config().Path, err)
 		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
 		return
 	}
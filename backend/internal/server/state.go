@@ -0,0 +1,133 @@
+package server
+
+// The lifecycle of the Factorio process, modelled as a state machine that
+// records every transition and notifies subscribers.
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ServerState is a lifecycle state of the managed Factorio server.
+type ServerState string
+
+const (
+	StateCrashed  ServerState = "crashed"
+	StateRunning  ServerState = "running"
+	StateStarting ServerState = "starting"
+	StateStopped  ServerState = "stopped"
+	StateStopping ServerState = "stopping"
+	StateUpdating ServerState = "updating"
+)
+
+// maxStateHistory bounds the number of transitions kept for /status.
+const maxStateHistory = 50
+
+// StateTransition records a single change of server state.
+type StateTransition struct {
+	From   ServerState `json:"from"`
+	Reason string      `json:"reason,omitempty"`
+	Time   time.Time   `json:"time"`
+	To     ServerState `json:"to"`
+}
+
+// StateError is returned when an operation conflicts with the current server state.
+type StateError struct {
+	Op    string
+	State ServerState
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s while server is %s", e.Op, e.State)
+}
+
+// setState moves the server to a new state, records the transition and
+// notifies subscribers. The caller must hold s.mu.
+func (s *ServerManager) setState(to ServerState, reason string) {
+	if s.state == to {
+		return
+	}
+
+	transition := StateTransition{
+		From:   s.state,
+		Reason: reason,
+		Time:   time.Now(),
+		To:     to,
+	}
+	s.state = to
+	s.stateHistory = append(s.stateHistory, transition)
+	if len(s.stateHistory) > maxStateHistory {
+		s.stateHistory = s.stateHistory[len(s.stateHistory)-maxStateHistory:]
+	}
+	log.Printf("Server state changed from %s to %s\n", transition.From, transition.To)
+
+	switch to {
+	case StateRunning:
+		s.rcon.Connect()
+	case StateStopped, StateCrashed:
+		s.rcon.Disconnect()
+	}
+
+	for _, ch := range s.stateSubscribers {
+		select {
+		case ch <- transition:
+		default:
+		}
+	}
+}
+
+// requireState returns a StateError unless the server is in one of the allowed states.
+// The caller must hold s.mu.
+func (s *ServerManager) requireState(op string, allowed ...ServerState) error {
+	for _, state := range allowed {
+		if s.state == state {
+			return nil
+		}
+	}
+	return &StateError{Op: op, State: s.state}
+}
+
+// BeginUpdate moves a stopped server into the updating state so that version
+// changes cannot race with a start. Callers must call EndUpdate when done.
+func (s *ServerManager) BeginUpdate(op string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireState(op, StateStopped, StateCrashed); err != nil {
+		return err
+	}
+	s.cancelRestart()
+	s.setState(StateUpdating, op)
+	return nil
+}
+
+// EndUpdate returns the server to the stopped state after BeginUpdate.
+func (s *ServerManager) EndUpdate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == StateUpdating {
+		s.setState(StateStopped, "update finished")
+	}
+}
+
+// SubscribeToState returns a channel receiving every state transition along
+// with a function that removes the subscription.
+func (s *ServerManager) SubscribeToState() (<-chan StateTransition, func()) {
+	ch := make(chan StateTransition, 20)
+	s.mu.Lock()
+	s.stateSubscribers = append(s.stateSubscribers, ch)
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.stateSubscribers {
+			if sub == ch {
+				s.stateSubscribers = append(s.stateSubscribers[:i], s.stateSubscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
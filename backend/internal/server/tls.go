@@ -0,0 +1,139 @@
+package server
+
+// HTTPS support: certificates loaded from disk and reloaded when they change,
+// and a plain HTTP listener redirecting to HTTPS.
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/snarf-dev/fsm/v2/internal/config"
+)
+
+// certReloadDelay debounces bursts of file events while a certificate is renewed.
+const certReloadDelay = time.Second
+
+// certReloader serves the certificate at certPath/keyPath, reloading it whenever
+// either file changes. A failed reload keeps the previous certificate in use.
+type certReloader struct {
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certPath string
+	keyPath  string
+}
+
+// newCertReloader loads the certificate and starts watching it for changes.
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	c := &certReloader{certPath: certPath, keyPath: keyPath}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	if err := c.watch(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", c.certPath, err)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+	return nil
+}
+
+// watch reloads the certificate after changes in the directories holding the
+// certificate and key. Directories are watched rather than the files because
+// renewal tools usually replace files by renaming or swapping symlinks.
+func (c *certReloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string]bool{filepath.Dir(c.certPath): true, filepath.Dir(c.keyPath): true}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	var debounceTimer *time.Timer
+	var debounceMu sync.Mutex
+
+	go func() {
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				debounceMu.Lock()
+				if debounceTimer != nil {
+					debounceTimer.Stop()
+				}
+				debounceTimer = time.AfterFunc(certReloadDelay, func() {
+					if err := c.reload(); err != nil {
+						log.Printf("Keeping previous certificate: %v\n", err)
+						return
+					}
+					log.Printf("Reloaded TLS certificate %s\n", c.certPath)
+				})
+				debounceMu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("certificate watch error:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// tlsConfig builds the TLS configuration for the HTTPS listener.
+func tlsConfig(cfg config.ServerConfig, certs *certReloader) *tls.Config {
+	minVersion := uint16(tls.VersionTLS12)
+	if cfg.TLSMinVersion == config.TLSVersion13 {
+		minVersion = tls.VersionTLS13
+	}
+	return &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     minVersion,
+	}
+}
+
+// redirectToHTTPS returns a handler redirecting every request to the same URL on
+// the HTTPS listener at httpsAddr.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
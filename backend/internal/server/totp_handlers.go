@@ -0,0 +1,328 @@
+package server
+
+// HTTP handler functions for enrolling FSM admins in TOTP two-factor
+// authentication and managing their recovery codes.
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/snarf-dev/fsm/v2/internal/auth"
+	"github.com/snarf-dev/fsm/v2/internal/helpers"
+)
+
+const (
+	recoveryCodeCount  = 10               // Recovery codes issued per enrolment
+	totpEnrolmentTTL   = 10 * time.Minute // Time allowed to confirm a new secret
+	totpIssuer         = "FSM"            // Issuer shown in authenticator apps
+	totpRequiredReason = "Two-factor code required"
+)
+
+// errRecoveryCodeUnused aborts a config update when no recovery code matched.
+var errRecoveryCodeUnused = errors.New("no matching recovery code")
+
+// totpState holds enrolments awaiting confirmation and the last accepted time
+// step per admin, so a code cannot be replayed within its validity window.
+type totpState struct {
+	mu       sync.Mutex
+	lastStep map[string]int64
+	pending  map[string]pendingEnrolment
+}
+
+type pendingEnrolment struct {
+	expires time.Time
+	secret  string
+}
+
+// handleTOTPStatus reports whether the current admin has two-factor
+// authentication enabled and how many recovery codes remain.
+func (s *RestServer) handleTOTPStatus(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
+	user := currentUser(r)
+	var enabled bool
+	var recoveryCodes int
+	cfg.ViewAdmins(func() {
+		_, enabled = cfg.TOTP[user]
+		recoveryCodes = len(cfg.RecoveryCodes[user])
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"enabled":        enabled,
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// handleTOTPEnroll starts enrolment for the current admin and returns a new secret
+// and its otpauth:// provisioning URI for rendering as a QR code. The secret only
+// takes effect once confirmed through handleTOTPVerify.
+func (s *RestServer) handleTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	user := currentUser(r)
+	if _, enabled := s.config().AdminTOTPSecret(user); enabled {
+		helpers.RenderErrorJSON(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("Failed to generate TOTP secret: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to generate secret")
+		return
+	}
+
+	s.totp.mu.Lock()
+	if s.totp.pending == nil {
+		s.totp.pending = map[string]pendingEnrolment{}
+	}
+	s.totp.pending[user] = pendingEnrolment{expires: time.Now().Add(totpEnrolmentTTL), secret: secret}
+	s.totp.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret": secret,
+		"uri":    auth.TOTPProvisioningURI(totpIssuer, user, secret),
+	})
+}
+
+// handleTOTPVerify confirms a pending enrolment with a code from the authenticator
+// ("code" in the JSON body), enables two-factor authentication and returns a fresh
+// set of recovery codes. The codes are only shown once.
+func (s *RestServer) handleTOTPVerify(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	code, ok := decodeTOTPCode(w, r)
+	if !ok {
+		return
+	}
+
+	user := currentUser(r)
+	s.totp.mu.Lock()
+	pending, found := s.totp.pending[user]
+	if found && time.Now().After(pending.expires) {
+		delete(s.totp.pending, user)
+		found = false
+	}
+	s.totp.mu.Unlock()
+	if !found {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "No pending enrolment, start again")
+		return
+	}
+
+	step, valid := auth.ValidateTOTP(pending.secret, code, time.Now())
+	if !valid {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("Failed to generate recovery codes: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to generate recovery codes")
+		return
+	}
+
+	s.totp.mu.Lock()
+	delete(s.totp.pending, user)
+	s.setLastTOTPStep(user, step)
+	s.totp.mu.Unlock()
+
+	err = cfg.UpdateAdmins(func() error {
+		cfg.TOTP[user] = pending.secret
+		cfg.RecoveryCodes[user] = hashes
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to update %s, %v\n", cfg.Path, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
+		return
+	}
+	log.Printf("Two-factor authentication enabled for %s\n", user)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// handleTOTPRecoveryCodes replaces the current admin's recovery codes after checking
+// a code from the authenticator or an unused recovery code.
+func (s *RestServer) handleTOTPRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	code, ok := decodeTOTPCode(w, r)
+	if !ok {
+		return
+	}
+
+	user := currentUser(r)
+	if _, enabled := cfg.AdminTOTPSecret(user); !enabled {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Two-factor authentication is not enabled")
+		return
+	}
+	if !s.verifySecondFactor(user, code) {
+		helpers.RenderErrorJSON(w, http.StatusForbidden, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("Failed to generate recovery codes: %v\n", err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Unable to generate recovery codes")
+		return
+	}
+	err = cfg.UpdateAdmins(func() error {
+		cfg.RecoveryCodes[user] = hashes
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to update %s, %v\n", cfg.Path, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// handleTOTPDisable turns off two-factor authentication for the current admin after
+// checking a code from the authenticator or an unused recovery code.
+func (s *RestServer) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSession(w, r) {
+		return
+	}
+
+	code, ok := decodeTOTPCode(w, r)
+	if !ok {
+		return
+	}
+
+	user := currentUser(r)
+	if _, enabled := s.config().AdminTOTPSecret(user); !enabled {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Two-factor authentication is not enabled")
+		return
+	}
+	if !s.verifySecondFactor(user, code) {
+		helpers.RenderErrorJSON(w, http.StatusForbidden, "Invalid code")
+		return
+	}
+
+	s.disableTOTP(w, user)
+}
+
+// handleResetAdminTOTP turns off two-factor authentication for another admin,
+// e.g. after they lost their authenticator and recovery codes.
+func (s *RestServer) handleResetAdminTOTP(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["user"]
+	if _, enabled := s.config().AdminTOTPSecret(user); !enabled {
+		helpers.RenderErrorJSON(w, http.StatusNotFound, "Two-factor authentication is not enabled")
+		return
+	}
+	log.Printf("Two-factor authentication of %s reset by %s\n", user, currentUser(r))
+	s.disableTOTP(w, user)
+}
+
+// disableTOTP removes the TOTP secret and recovery codes of user.
+func (s *RestServer) disableTOTP(w http.ResponseWriter, user string) {
+	cfg := s.config()
+	err := cfg.UpdateAdmins(func() error {
+		delete(cfg.TOTP, user)
+		delete(cfg.RecoveryCodes, user)
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to update %s, %v\n", cfg.Path, err)
+		helpers.RenderErrorJSON(w, http.StatusInternalServerError, "Failed to save config")
+		return
+	}
+	log.Printf("Two-factor authentication disabled for %s\n", user)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySecondFactor checks code against the admin's TOTP secret, falling back
+// to their recovery codes. Used recovery codes are removed from the config and
+// TOTP codes are rejected if their time step was already used.
+func (s *RestServer) verifySecondFactor(user, code string) bool {
+	cfg := s.config()
+	secret, ok := cfg.AdminTOTPSecret(user)
+	if !ok {
+		return false
+	}
+
+	if step, valid := auth.ValidateTOTP(secret, code, time.Now()); valid {
+		s.totp.mu.Lock()
+		defer s.totp.mu.Unlock()
+		if step <= s.totp.lastStep[user] {
+			return false
+		}
+		s.setLastTOTPStep(user, step)
+		return true
+	}
+
+	var used bool
+	var left int
+	err := cfg.UpdateAdmins(func() error {
+		var remaining []string
+		if remaining, used = auth.UseRecoveryCode(cfg.RecoveryCodes[user], code); !used {
+			return errRecoveryCodeUnused
+		}
+		cfg.RecoveryCodes[user] = remaining
+		left = len(remaining)
+		return nil
+	})
+	if !used {
+		return false
+	}
+	if err != nil {
+		log.Printf("failed to update %s, %v\n", cfg.Path, err)
+	}
+	log.Printf("%s used a recovery code, %d left\n", user, left)
+	return true
+}
+
+// setLastTOTPStep records the last accepted time step of user. Callers must hold s.totp.mu.
+func (s *RestServer) setLastTOTPStep(user string, step int64) {
+	if s.totp.lastStep == nil {
+		s.totp.lastStep = map[string]int64{}
+	}
+	s.totp.lastStep[user] = step
+}
+
+// renderTOTPRequired rejects a login whose password was accepted but which lacks
+// a valid second factor. The totp_required flag tells clients to prompt for a code.
+func renderTOTPRequired(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]any{
+		"code":          http.StatusUnauthorized,
+		"message":       message,
+		"totp_required": true,
+	})
+}
+
+// decodeTOTPCode reads the "code" field of the JSON body.
+func decodeTOTPCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Invalid JSON")
+		return "", false
+	}
+	if payload.Code == "" {
+		helpers.RenderErrorJSON(w, http.StatusBadRequest, "Code is required")
+		return "", false
+	}
+	return payload.Code, true
+}
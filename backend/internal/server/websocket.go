@@ -0,0 +1,110 @@
+package server
+
+// Shared WebSocket plumbing: origin checks, keepalive pings, client disconnect
+// detection and deadline-bound writes.
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPongWait     = 60 * time.Second
+	wsPingPeriod   = (wsPongWait * 9) / 10
+	wsWriteWait    = 10 * time.Second
+	wsMaxReadBytes = 4096
+)
+
+// upgrade upgrades r to a WebSocket connection, refusing cross-origin handshakes
+// from origins that are not allowed by the config.
+func (s *RestServer) upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	return upgrader.Upgrade(w, r, nil)
+}
+
+// checkOrigin accepts handshakes without an Origin header (non-browser clients),
+// from the same host as the request, or from an allowed origin.
+func (s *RestServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || s.isAllowedOrigin(origin)
+}
+
+// isAllowedOrigin reports whether origin is listed in the allowed_origins setting.
+// Cross-origin requests carry credentials, so only exact matches are allowed and
+// wildcards are rejected when the config is loaded.
+func (s *RestServer) isAllowedOrigin(origin string) bool {
+	for _, allowed := range s.config().Server.AllowedOrigins {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepAlive runs a read pump and a ping loop for conn. Incoming messages are passed
+// to onMessage when it is non-nil and discarded otherwise. The returned context is
+// cancelled as soon as the client disconnects, stops answering pings, or parent is done;
+// in the latter case the client is sent a "going away" close frame.
+func keepAlive(parent context.Context, conn *websocket.Conn, onMessage func([]byte)) context.Context {
+	ctx, cancel := context.WithCancel(parent)
+
+	conn.SetReadLimit(wsMaxReadBytes)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		defer cancel()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if onMessage != nil {
+				onMessage(msg)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if parent.Err() != nil {
+					msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+					conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+				}
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx
+}
+
+// writeJSON writes v to conn as JSON, failing if the client does not accept it in time.
+func writeJSON(conn *websocket.Conn, v interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(v)
+}
@@ -0,0 +1,269 @@
+// Package validators checks user input such as admin usernames, and derives
+// schemas for Factorio's map-gen-settings.json and map-settings.json from the
+// example files shipped with each Factorio version to validate edits against.
+package validators
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindAny kind = iota
+	kindArray
+	kindBool
+	kindNumber
+	kindObject
+	kindString
+)
+
+var kindNames = map[kind]string{
+	kindArray:  "an array",
+	kindBool:   "a boolean",
+	kindNumber: "a number",
+	kindObject: "an object",
+	kindString: "a string",
+}
+
+// Range constrains a numeric setting. Paths use "*" for any key of an open
+// object and "[]" for array elements, e.g. "autoplace_controls.*.size".
+type Range struct {
+	Integer bool     // Whether the value must be a whole number
+	Max     *float64 // Largest allowed value when set
+	Min     *float64 // Smallest allowed value when set
+}
+
+// SchemaRules are the constraints the example files cannot express.
+type SchemaRules struct {
+	Open   []string         // Paths of objects keyed by prototype names, which accept any key
+	Ranges map[string]Range // Numeric constraints keyed by path
+}
+
+// FieldError describes why a single setting was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Schema describes the structure of a settings file.
+type Schema struct {
+	children map[string]*Schema
+	elem     *Schema // Element schema of arrays and value schema of open objects
+	kind     kind
+	nullable bool
+	open     bool
+	rng      *Range
+}
+
+func ptr(f float64) *float64 { return &f }
+
+// MapGenSettingsRules constrain map-gen-settings.json.
+var MapGenSettingsRules = SchemaRules{
+	Open: []string{"autoplace_controls", "autoplace_settings", "property_expression_names"},
+	Ranges: map[string]Range{
+		"autoplace_controls.*.frequency":          {Min: ptr(0)},
+		"autoplace_controls.*.richness":           {Min: ptr(0)},
+		"autoplace_controls.*.size":               {Min: ptr(0)},
+		"cliff_settings.cliff_elevation_0":        {Min: ptr(0)},
+		"cliff_settings.cliff_elevation_interval": {Min: ptr(0)},
+		"cliff_settings.richness":                 {Min: ptr(0)},
+		"height":                                  {Integer: true, Min: ptr(0), Max: ptr(2000000)},
+		"seed":                                    {Integer: true, Min: ptr(0), Max: ptr(math.MaxUint32)},
+		"starting_area":                           {Min: ptr(0)},
+		"terrain_segmentation":                    {Min: ptr(0)},
+		"water":                                   {Min: ptr(0)},
+		"width":                                   {Integer: true, Min: ptr(0), Max: ptr(2000000)},
+	},
+}
+
+// MapSettingsRules constrain map-settings.json.
+var MapSettingsRules = SchemaRules{
+	Ranges: map[string]Range{
+		"difficulty_settings.technology_price_multiplier":       {Min: ptr(0.001), Max: ptr(1000)},
+		"enemy_evolution.destroy_factor":                        {Min: ptr(0)},
+		"enemy_evolution.pollution_factor":                      {Min: ptr(0)},
+		"enemy_evolution.time_factor":                           {Min: ptr(0)},
+		"enemy_expansion.max_expansion_cooldown":                {Integer: true, Min: ptr(0)},
+		"enemy_expansion.max_expansion_distance":                {Integer: true, Min: ptr(0)},
+		"enemy_expansion.min_expansion_cooldown":                {Integer: true, Min: ptr(0)},
+		"enemy_expansion.settler_group_max_size":                {Integer: true, Min: ptr(1)},
+		"enemy_expansion.settler_group_min_size":                {Integer: true, Min: ptr(1)},
+		"max_failed_behavior_count":                             {Integer: true, Min: ptr(0)},
+		"pollution.ageing":                                      {Min: ptr(0)},
+		"pollution.diffusion_ratio":                             {Min: ptr(0), Max: ptr(0.25)},
+		"pollution.enemy_attack_pollution_consumption_modifier": {Min: ptr(0)},
+		"pollution.min_pollution_to_damage_trees":               {Min: ptr(0)},
+		"pollution.min_to_diffuse":                              {Min: ptr(0)},
+	},
+}
+
+// SchemaFromExample derives a schema from the contents of an example settings
+// file. Keys starting with "_" are comments and are ignored. A null example
+// value accepts anything unless a range applies, in which case it accepts a
+// number or null.
+func SchemaFromExample(data []byte, rules SchemaRules) (*Schema, error) {
+	var example interface{}
+	if err := json.Unmarshal(data, &example); err != nil {
+		return nil, fmt.Errorf("failed to parse example: %w", err)
+	}
+	open := map[string]bool{}
+	for _, path := range rules.Open {
+		open[path] = true
+	}
+	return derive("", example, open, rules.Ranges), nil
+}
+
+func derive(path string, value interface{}, open map[string]bool, ranges map[string]Range) *Schema {
+	schema := &Schema{}
+	if rng, ok := ranges[path]; ok {
+		schema.rng = &rng
+	}
+
+	switch v := value.(type) {
+	case nil:
+		if schema.rng != nil {
+			schema.kind = kindNumber
+		}
+		schema.nullable = true
+	case bool:
+		schema.kind = kindBool
+	case float64:
+		schema.kind = kindNumber
+	case string:
+		schema.kind = kindString
+	case []interface{}:
+		schema.kind = kindArray
+		if len(v) > 0 {
+			schema.elem = derive(path+"[]", v[0], open, ranges)
+		} else {
+			schema.elem = &Schema{}
+		}
+	case map[string]interface{}:
+		schema.kind = kindObject
+		if open[path] {
+			schema.open = true
+			schema.elem = &Schema{}
+			for _, key := range sortedKeys(v) {
+				if !strings.HasPrefix(key, "_") {
+					schema.elem = derive(join(path, "*"), v[key], open, ranges)
+					break
+				}
+			}
+			return schema
+		}
+		schema.children = map[string]*Schema{}
+		for key, child := range v {
+			if !strings.HasPrefix(key, "_") {
+				schema.children[key] = derive(join(path, key), child, open, ranges)
+			}
+		}
+	}
+	return schema
+}
+
+// Validate checks the settings in value, which may be a subset of the file.
+// It returns one error per rejected field, sorted by field.
+func (s *Schema) Validate(value interface{}) []FieldError {
+	var errs []FieldError
+	s.validate("", value, &errs)
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		field := path
+		if field == "" {
+			field = "(root)"
+		}
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !s.nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s.kind {
+	case kindAny:
+	case kindBool:
+		if _, ok := value.(bool); !ok {
+			fail("must be %s", kindNames[s.kind])
+		}
+	case kindString:
+		if _, ok := value.(string); !ok {
+			fail("must be %s", kindNames[s.kind])
+		}
+	case kindNumber:
+		n, ok := value.(float64)
+		if !ok {
+			fail("must be %s", kindNames[s.kind])
+			return
+		}
+		if s.rng == nil {
+			return
+		}
+		if s.rng.Integer && n != math.Trunc(n) {
+			fail("must be a whole number")
+		}
+		if s.rng.Min != nil && n < *s.rng.Min {
+			fail("must be at least %s", strconv.FormatFloat(*s.rng.Min, 'f', -1, 64))
+		}
+		if s.rng.Max != nil && n > *s.rng.Max {
+			fail("must be at most %s", strconv.FormatFloat(*s.rng.Max, 'f', -1, 64))
+		}
+	case kindArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be %s", kindNames[s.kind])
+			return
+		}
+		for i, item := range items {
+			s.elem.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case kindObject:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be %s", kindNames[s.kind])
+			return
+		}
+		for _, key := range sortedKeys(obj) {
+			if strings.HasPrefix(key, "_") {
+				continue
+			}
+			child := s.elem
+			if !s.open {
+				child = s.children[key]
+			}
+			if child == nil {
+				*errs = append(*errs, FieldError{Field: join(path, key), Message: "unknown setting"})
+				continue
+			}
+			child.validate(join(path, key), obj[key], errs)
+		}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
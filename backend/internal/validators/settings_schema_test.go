@@ -0,0 +1,113 @@
+package validators
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const mapGenExample = `{
+	"_terrain_segmentation_comment": "Inverse of map scale",
+	"terrain_segmentation": 1,
+	"water": 1,
+	"width": 0,
+	"height": 0,
+	"starting_area": 1,
+	"peaceful_mode": false,
+	"autoplace_controls": {
+		"coal": {"frequency": 1, "size": 1, "richness": 1}
+	},
+	"cliff_settings": {"name": "cliff", "cliff_elevation_0": 10, "richness": 1},
+	"property_expression_names": {},
+	"starting_points": [{"x": 0, "y": 0}],
+	"_seed_comment": "Use null for a random seed",
+	"seed": null,
+	"extra": null
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := SchemaFromExample([]byte(mapGenExample), MapGenSettingsRules)
+	if err != nil {
+		t.Fatalf("SchemaFromExample() = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		want    []FieldError
+	}{
+		{name: "empty", payload: `{}`},
+		{name: "valid subset", payload: `{"water": 1.5, "peaceful_mode": true, "cliff_settings": {"name": "cliff"}}`},
+		{name: "comment keys ignored", payload: `{"_comment": 1, "cliff_settings": {"_comment": [1]}}`},
+		{name: "wrong types", payload: `{"water": "lots", "peaceful_mode": 1, "cliff_settings": {"name": 3}}`, want: []FieldError{
+			{Field: "cliff_settings.name", Message: "must be a string"},
+			{Field: "peaceful_mode", Message: "must be a boolean"},
+			{Field: "water", Message: "must be a number"},
+		}},
+		{name: "object expected", payload: `{"cliff_settings": 1}`, want: []FieldError{{Field: "cliff_settings", Message: "must be an object"}}},
+		{name: "array expected", payload: `{"starting_points": {"x": 0}}`, want: []FieldError{{Field: "starting_points", Message: "must be an array"}}},
+		{name: "array elements", payload: `{"starting_points": [{"x": 1, "y": 2}, {"x": "a"}, {"z": 0}]}`, want: []FieldError{
+			{Field: "starting_points[1].x", Message: "must be a number"},
+			{Field: "starting_points[2].z", Message: "unknown setting"},
+		}},
+		{name: "unknown keys", payload: `{"wter": 1, "cliff_settings": {"size": 1}}`, want: []FieldError{
+			{Field: "cliff_settings.size", Message: "unknown setting"},
+			{Field: "wter", Message: "unknown setting"},
+		}},
+		{name: "below minimum", payload: `{"water": -1}`, want: []FieldError{{Field: "water", Message: "must be at least 0"}}},
+		{name: "minimum is inclusive", payload: `{"water": 0, "width": 0}`},
+		{name: "maximum is inclusive", payload: `{"width": 2000000, "seed": 4294967295}`},
+		{name: "above maximum", payload: `{"width": 2000001, "seed": 4294967296}`, want: []FieldError{
+			{Field: "seed", Message: "must be at most 4294967295"},
+			{Field: "width", Message: "must be at most 2000000"},
+		}},
+		{name: "whole number required", payload: `{"height": 10.5}`, want: []FieldError{{Field: "height", Message: "must be a whole number"}}},
+		{name: "open object accepts any key", payload: `{"autoplace_controls": {"iron-ore": {"size": 2}, "stone": {"richness": 0}}}`},
+		{name: "open object values are checked", payload: `{"autoplace_controls": {"iron-ore": {"size": -1, "amount": 1}, "stone": 3}}`, want: []FieldError{
+			{Field: "autoplace_controls.iron-ore.amount", Message: "unknown setting"},
+			{Field: "autoplace_controls.iron-ore.size", Message: "must be at least 0"},
+			{Field: "autoplace_controls.stone", Message: "must be an object"},
+		}},
+		{name: "empty open object accepts anything", payload: `{"property_expression_names": {"elevation": "x", "moisture": 1}}`},
+		{name: "null where the example has null", payload: `{"seed": null, "extra": null}`},
+		{name: "null example with range takes a number", payload: `{"seed": 123}`},
+		{name: "null example with range rejects other types", payload: `{"seed": "123"}`, want: []FieldError{{Field: "seed", Message: "must be a number"}}},
+		{name: "null example without range accepts anything", payload: `{"extra": {"a": [1, "b"]}}`},
+		{name: "null where the example has a value", payload: `{"water": null, "cliff_settings": null}`, want: []FieldError{
+			{Field: "cliff_settings", Message: "must not be null"},
+			{Field: "water", Message: "must not be null"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload interface{}
+			if err := json.Unmarshal([]byte(tt.payload), &payload); err != nil {
+				t.Fatalf("invalid payload: %v", err)
+			}
+			got := schema.Validate(payload)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaValidateRoot(t *testing.T) {
+	schema, err := SchemaFromExample([]byte(`{"water": 1}`), SchemaRules{})
+	if err != nil {
+		t.Fatalf("SchemaFromExample() = %v", err)
+	}
+	want := []FieldError{{Field: "(root)", Message: "must be an object"}}
+	if got := schema.Validate([]interface{}{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSchemaFromExampleInvalid(t *testing.T) {
+	if _, err := SchemaFromExample([]byte(`{"water": `), SchemaRules{}); err == nil {
+		t.Error("SchemaFromExample() of invalid JSON succeeded")
+	}
+}
@@ -1,24 +1,53 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/snarf-dev/fsm/v2/internal/config"
 	"github.com/snarf-dev/fsm/v2/internal/server"
 )
 
+// Exit codes of the FSM process.
+const (
+	exitOK      = 0 // Clean shutdown
+	exitFailure = 1 // Invalid config or the HTTP server could not run
+	exitUnclean = 2 // Shutdown completed but the Factorio server was not stopped cleanly
+)
+
 func main() {
 	configPath := flag.String("config", "", "Path to config file")
 	flag.Parse()
 
 	err, cfg := config.Load(configPath)
 	if err != nil {
-		log.Println("unable to use config, exiting")
-		os.Exit(1)
+		log.Printf("unable to use config, exiting: %v\n", err)
+		os.Exit(exitFailure)
 	}
 
-	server := server.CreateRestServer(cfg)
-	server.Start()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// Restore the default handlers once shutdown starts, so a second signal
+		// terminates FSM immediately.
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = server.CreateRestServer(cfg).Start(ctx)
+	switch {
+	case err == nil:
+		log.Println("Shutdown complete")
+		os.Exit(exitOK)
+	case errors.Is(err, server.ErrUncleanShutdown):
+		log.Printf("Shutdown incomplete: %v\n", err)
+		os.Exit(exitUnclean)
+	default:
+		log.Printf("Exiting: %v\n", err)
+		os.Exit(exitFailure)
+	}
 }
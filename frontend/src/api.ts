@@ -3,15 +3,14 @@ export const API_BASE = import.meta.env.VITE_API_BASE || ''
 import type { Mod } from '@/types/mod'
 
 export const fetchWithAuth = async (url: string, options: RequestInit = {}): Promise<Response> => {
-  const username = localStorage.getItem('username') || ''
-  const password = localStorage.getItem('password') || ''
+  const token = localStorage.getItem('token') || ''
   const headers = new Headers(options.headers || {})
 
   if (!headers.has('Authorization')) {
-    if (username === '' && password === '') {
+    if (token === '') {
       throw Error('No logged in')
     }
-    headers.set('Authorization', 'Basic ' + btoa(`${username}:${password}`))
+    headers.set('Authorization', `Bearer ${token}`)
   }
   if (options.body && !headers.has('Content-Type') && !(options.body instanceof FormData)) {
     headers.set('Content-Type', 'application/json')
@@ -23,6 +22,49 @@ export const fetchWithAuth = async (url: string, options: RequestInit = {}): Pro
   })
 }
 
+// wsUrl returns the WebSocket URL for path, authenticated with the session token.
+export const wsUrl = (path: string) => {
+  const base = API_BASE.replace(/^http/, 'ws')
+  const separator = path.includes('?') ? '&' : '?'
+  return `${base}${path}${separator}token=${encodeURIComponent(localStorage.getItem('token') || '')}`
+}
+
+// ----------------------------------------------------------------------------
+// Session
+// ----------------------------------------------------------------------------
+
+export class TOTPRequiredError extends Error {}
+
+export const login = async (username: string, password: string, code = '') => {
+  const res = await fetch(`${API_BASE}/login`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ username, password, code }),
+  })
+  if (!res.ok) {
+    const body = await res.json().catch(() => null)
+    if (body?.totp_required) {
+      throw new TOTPRequiredError(body.message)
+    }
+    const message = body?.message || null
+    throw Error('Login failed.' + (message ? `\n${message}` : ''))
+  }
+  const session = await res.json()
+  localStorage.setItem('token', session.token)
+  localStorage.setItem('username', session.username)
+  return session
+}
+
+export const logout = async () => {
+  try {
+    await fetchWithAuth(`${API_BASE}/logout`, { method: 'POST' })
+  } catch (e) {
+    console.log('Unable to log out', e)
+  }
+  localStorage.removeItem('token')
+  localStorage.removeItem('username')
+}
+
 // ----------------------------------------------------------------------------
 // FSM Admins
 // ----------------------------------------------------------------------------